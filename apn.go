@@ -0,0 +1,80 @@
+package main
+
+import (
+	"github.com/alexflint/go-arg"
+	"github.com/brokenCursor/usb-modem-cli/drivers"
+	"github.com/i582/cfmt/cmd/cfmt"
+)
+
+func runAPN(parser *arg.Parser, modem drivers.BaseModem) error {
+	apn, ok := modem.(drivers.ModemAPN)
+	if !ok {
+		return DriverSupportError{Driver: modem, Function: "APN profiles"}
+	}
+
+	switch {
+	case args.APN.List != nil:
+		profiles, err := apn.ListAPN()
+		if err != nil {
+			return err
+		}
+
+		for _, profile := range profiles {
+			cfmt.Printf("{{Index:}}::cyan %d", profile.Index)
+			if profile.Default {
+				cfmt.Print(" {{(default)}}::green|bold")
+			}
+			if profile.Preset {
+				cfmt.Print(" {{(preset)}}::yellow")
+			}
+			cfmt.Printf("\n{{Name:}}::green %s\n{{APN:}}::green %s\n{{PDP type:}}::green %s\n{{Auth:}}::green %s\n", profile.Name, profile.APN, profile.PDPType, profile.AuthType)
+			if profile.Username != "" {
+				cfmt.Printf("{{Username:}}::green %s\n", profile.Username)
+			}
+			cfmt.Println("---")
+		}
+	case args.APN.Add != nil:
+		if err := validate.Struct(args.APN.Add); err != nil {
+			logger.With("err", err.Error()).Debug("apn add validation error")
+			parser.FailSubcommand("Unknown values", "apn", "add")
+		}
+
+		return apn.AddAPN(profileFromArgs(args.APN.Add))
+	case args.APN.Edit != nil:
+		if err := validate.Struct(args.APN.Edit); err != nil {
+			logger.With("err", err.Error()).Debug("apn edit validation error")
+			parser.FailSubcommand("Unknown values", "apn", "edit")
+		}
+
+		profile := profileFromArgs(&args.APN.Edit.APNProfileArgs)
+		profile.Index = args.APN.Edit.Index
+		return apn.EditAPN(profile)
+	case args.APN.SetDefault != nil:
+		if err := validate.Struct(args.APN.SetDefault); err != nil {
+			parser.FailSubcommand("Invalid index", "apn", "set-default")
+		}
+
+		return apn.SetDefaultAPN(args.APN.SetDefault.Index)
+	case args.APN.Delete != nil:
+		if err := validate.Struct(args.APN.Delete); err != nil {
+			parser.FailSubcommand("Invalid index", "apn", "delete")
+		}
+
+		return apn.DeleteAPN(args.APN.Delete.Index)
+	default:
+		parser.FailSubcommand("Missing or unknown action", "apn")
+	}
+
+	return nil
+}
+
+func profileFromArgs(a *APNProfileArgs) drivers.APNProfile {
+	return drivers.APNProfile{
+		Name:     a.Name,
+		APN:      a.APN,
+		PDPType:  a.PDPType,
+		AuthType: a.AuthType,
+		Username: a.Username,
+		Password: a.Password,
+	}
+}
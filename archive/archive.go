@@ -0,0 +1,234 @@
+package archive
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/brokenCursor/usb-modem-cli/drivers"
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	bucketMessages = []byte("messages")
+	bucketIndex    = []byte("index")
+)
+
+// How long to wait for another process holding the archive
+const lockTimeout = 5 * time.Second
+
+var ErrLocked = errors.New("archive is in use by another process")
+
+// Message stored in the archive
+type Record struct {
+	drivers.SMS
+	Modem    string    `json:"modem"`           // Model and host of the modem it was read from
+	ICCID    string    `json:"iccid,omitempty"` // SIM it was read with, if known
+	Hash     string    `json:"hash"`
+	Archived time.Time `json:"archived"`
+}
+
+type Query struct {
+	Text   string // Every word has to be found, words match by prefix
+	Sender string // Number or name, compared with drivers.SamePhone
+	Since  time.Time
+	Until  time.Time
+	Limit  int
+}
+
+type Archive struct {
+	db *bolt.DB
+}
+
+func Open(path string) (*Archive, error) {
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: lockTimeout})
+	if errors.Is(err, bolt.ErrTimeout) {
+		return nil, ErrLocked
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{bucketMessages, bucketIndex} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &Archive{db: db}, nil
+}
+
+func (a *Archive) Close() error {
+	return a.db.Close()
+}
+
+// Stores a message unless it's already archived. Modems reuse IDs once messages
+// are deleted, so a message is identified by its ID and a hash of its content
+func (a *Archive) Add(rec Record) (added bool, err error) {
+	rec.Hash = contentHash(&rec.SMS)
+	key := recordKey(&rec)
+
+	err = a.db.Update(func(tx *bolt.Tx) error {
+		messages := tx.Bucket(bucketMessages)
+		if messages.Get(key) != nil {
+			return nil
+		}
+
+		if rec.Archived.IsZero() {
+			rec.Archived = time.Now()
+		}
+		data, err := json.Marshal(&rec)
+		if err != nil {
+			return err
+		}
+		if err := messages.Put(key, data); err != nil {
+			return err
+		}
+
+		index := tx.Bucket(bucketIndex)
+		for _, word := range words(rec.Message + " " + rec.Sender) {
+			if err := index.Put(indexKey(word, key), nil); err != nil {
+				return err
+			}
+		}
+
+		added = true
+		return nil
+	})
+
+	return added, err
+}
+
+// Returns archived messages matching the query, newest first
+func (a *Archive) Search(q Query) ([]Record, error) {
+	records := []Record{}
+
+	err := a.db.View(func(tx *bolt.Tx) error {
+		keys, all := [][]byte(nil), true
+		if terms := words(q.Text); len(terms) > 0 {
+			keys, all = lookup(tx.Bucket(bucketIndex), terms), false
+		}
+
+		messages := tx.Bucket(bucketMessages)
+		add := func(data []byte) error {
+			rec := Record{}
+			if err := json.Unmarshal(data, &rec); err != nil {
+				return err
+			}
+			if q.match(&rec) {
+				records = append(records, rec)
+			}
+			return nil
+		}
+
+		if all {
+			return messages.ForEach(func(_, v []byte) error { return add(v) })
+		}
+		for _, key := range keys {
+			if data := messages.Get(key); data != nil {
+				if err := add(data); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	slices.SortFunc(records, func(a, b Record) int {
+		if c := b.Time.Compare(a.Time); c != 0 {
+			return c
+		}
+		return drivers.CompareSMSID(b.ID, a.ID)
+	})
+	if q.Limit > 0 && len(records) > q.Limit {
+		records = records[:q.Limit]
+	}
+
+	return records, nil
+}
+
+func (q *Query) match(rec *Record) bool {
+	switch {
+	case q.Sender != "" && !drivers.SamePhone(rec.Sender, q.Sender):
+		return false
+	case !q.Since.IsZero() && rec.Time.Before(q.Since):
+		return false
+	case !q.Until.IsZero() && rec.Time.After(q.Until):
+		return false
+	}
+
+	return true
+}
+
+// Returns keys of messages containing every term
+func lookup(index *bolt.Bucket, terms []string) [][]byte {
+	var found map[string]bool
+
+	for _, term := range terms {
+		matches := map[string]bool{}
+
+		c := index.Cursor()
+		prefix := []byte(term)
+		for k, _ := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, _ = c.Next() {
+			_, key, ok := bytes.Cut(k, []byte{0})
+			if ok && (found == nil || found[string(key)]) {
+				matches[string(key)] = true
+			}
+		}
+
+		found = matches
+		if len(found) == 0 {
+			return nil
+		}
+	}
+
+	keys := make([][]byte, 0, len(found))
+	for key := range found {
+		keys = append(keys, []byte(key))
+	}
+	return keys
+}
+
+// Splits text into lowercase words for the index
+func words(text string) []string {
+	fields := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	slices.Sort(fields)
+	return slices.Compact(fields)
+}
+
+func contentHash(sms *drivers.SMS) string {
+	h := sha256.New()
+	for _, field := range []string{string(sms.Folder), sms.Sender, sms.Time.UTC().Format(time.RFC3339), sms.Message} {
+		h.Write([]byte(field))
+		h.Write([]byte{0})
+	}
+
+	return hex.EncodeToString(h.Sum(nil))[:16]
+}
+
+func recordKey(rec *Record) []byte {
+	return []byte(rec.Modem + "/" + rec.ID + "/" + rec.Hash)
+}
+
+func indexKey(word string, key []byte) []byte {
+	return append([]byte(word+"\x00"), key...)
+}
@@ -1,28 +1,293 @@
 package main
 
+import "time"
+
 // CLI argument definitions
 type (
 	SMSActionArgs struct {
-		Send *SMSSendArgs `validate:"-" arg:"subcommand:send" help:"Send SMS"`
-		Read *SMSReadArgs `validate:"-" arg:"subcommand:read" help:"Read SMS"`
+		Send     *SMSSendArgs     `validate:"-" arg:"subcommand:send" help:"Send SMS"`
+		Read     *SMSReadArgs     `validate:"-" arg:"subcommand:read" help:"Read SMS"`
+		Delete   *SMSDeleteArgs   `validate:"-" arg:"subcommand:delete" help:"Delete SMS"`
+		MarkRead *SMSMarkReadArgs `validate:"-" arg:"subcommand:mark-read" help:"Mark SMS as read"`
+		Count    *SMSCountArgs    `validate:"-" arg:"subcommand:count" help:"Show encoding and length of a message"`
+		Bulk     *SMSBulkArgs     `validate:"-" arg:"subcommand:bulk" help:"Send templated SMS to recipients from a CSV file"`
+		Status   *SMSStatusArgs   `validate:"-" arg:"subcommand:status" help:"Show delivery state of a sent message"`
+		Watch    *SMSWatchArgs    `validate:"-" arg:"subcommand:watch" help:"Print incoming SMS as they arrive"`
+		Sync     *SMSSyncArgs     `validate:"-" arg:"subcommand:sync" help:"Copy all messages into the local archive"`
+		Search   *SMSSearchArgs   `validate:"-" arg:"subcommand:search" help:"Search the local archive"`
+		Export   *SMSExportArgs   `validate:"-" arg:"subcommand:export" help:"Write messages to a file"`
+		Import   *SMSImportArgs   `validate:"-" arg:"subcommand:import" help:"Store messages from an export on the modem"`
+		Capacity *SMSCapacityArgs `validate:"-" arg:"subcommand:capacity" help:"Show how full message storage is"`
+		Prune    *SMSPruneArgs    `validate:"-" arg:"subcommand:prune" help:"Delete oldest read messages when storage is nearly full"`
+		Schedule *SMSScheduleArgs `validate:"-" arg:"subcommand:schedule" help:"Send SMS later or repeatedly, run by the daemon"`
+		Outbox   *SMSOutboxArgs   `validate:"-" arg:"subcommand:outbox" help:"Manage messages queued with send --queue"`
 	}
 
 	SMSSendArgs struct {
-		PhoneNumber string `validate:"e164" arg:"-p,--phone,required" help:"Receiver's phone number"`
-		Message     string `validate:"printascii" arg:"-m,--msg,required" help:"Message to be sent"`
+		PhoneNumber string        `validate:"required" arg:"-p,--phone,required" help:"Receiver's phone number, national ones use phone.region"`
+		Message     string        `validate:"required_without=File,excluded_with=File,omitempty,utf8" arg:"-m,--msg" help:"Message to be sent, \"-\" to read it from stdin"`
+		File        string        `validate:"omitempty,file" arg:"--file" help:"Read message from a file"`
+		Encoding    string        `validate:"oneof=auto gsm7 ucs2" arg:"--encoding" default:"auto" help:"Message encoding: auto/gsm7/ucs2"`
+		Report      bool          `arg:"--report" help:"Request a delivery report, some modems keep asking for reports for later messages"`
+		Wait        time.Duration `validate:"gte=0" arg:"--wait" help:"Wait this long for the delivery report"`
+		Queue       bool          `validate:"excluded_with=Wait" arg:"--queue" help:"Put the message into the outbox for the daemon to deliver"`
+	}
+
+	SMSStatusArgs struct {
+		Ref string `validate:"required" arg:"positional,required" help:"Message reference returned by send"`
 	}
 
 	SMSReadArgs struct {
+		Folder string `validate:"oneof=inbox sent drafts all" arg:"--folder" default:"inbox" help:"inbox/sent/drafts/all"`
+		Unread bool   `arg:"--unread" help:"Only show unread messages"`
+		From   string `arg:"--from" help:"Only show messages from this number or sender name"`
+		Since  string `arg:"--since" help:"Only show messages since a time or a duration ago, e.g. 24h"`
+		Until  string `arg:"--until" help:"Only show messages until a time or a duration ago"`
+		Limit  int    `validate:"gte=0" arg:"-n,--limit" help:"Show at most this many messages"`
+	}
+
+	SMSCountArgs struct {
+		Message  string `validate:"required_without=File,excluded_with=File,omitempty,utf8" arg:"-m,--msg" help:"Message to be counted, \"-\" to read it from stdin"`
+		File     string `validate:"omitempty,file" arg:"--file" help:"Read message from a file"`
+		Encoding string `validate:"oneof=auto gsm7 ucs2" arg:"--encoding" default:"auto" help:"Message encoding: auto/gsm7/ucs2"`
+	}
+
+	SMSBulkArgs struct {
+		CSV         string  `validate:"required,file" arg:"--csv,required" help:"CSV file with a header row"`
+		Template    string  `validate:"required,utf8" arg:"-t,--template,required" help:"Go template, columns are available as {{.Column}}"`
+		PhoneColumn string  `validate:"required" arg:"--phone-column" default:"Phone" help:"Column with phone numbers"`
+		Rate        float64 `validate:"gt=0" arg:"--rate" default:"10" help:"Messages per minute"`
+		Report      string  `validate:"required" arg:"--report" default:"bulk-report.csv" help:"Per-recipient result file, sent rows are skipped when it exists"`
+		Encoding    string  `validate:"oneof=auto gsm7 ucs2" arg:"--encoding" default:"auto" help:"Message encoding: auto/gsm7/ucs2"`
+		DryRun      bool    `arg:"--dry-run" help:"Only validate and render messages"`
+	}
+
+	SMSWatchArgs struct {
+		Interval time.Duration `validate:"gt=0" arg:"--interval" default:"5s" help:"How often to check for new messages"`
+		From     string        `arg:"--from" help:"Only messages from this number or sender name"`
+		Match    string        `arg:"--match" help:"Only messages matching this regular expression"`
+		Exec     string        `arg:"--exec" help:"Run a command for every message, with the message as JSON on stdin"`
+		Once     bool          `arg:"--once" help:"Exit after the first matching message"`
+	}
+
+	SMSSyncArgs struct {
+	}
+
+	SMSSearchArgs struct {
+		Text  string `arg:"positional" help:"Words to look for, matched by prefix"`
+		From  string `arg:"--from" help:"Only messages from this number or sender name"`
+		Since string `arg:"--since" help:"Only messages since a time or a duration ago, e.g. 24h"`
+		Until string `arg:"--until" help:"Only messages until a time or a duration ago"`
+		Limit int    `validate:"gte=0" arg:"-n,--limit" help:"Show at most this many messages"`
+	}
+
+	SMSExportArgs struct {
+		Format string `validate:"oneof=json csv mbox xml" arg:"-f,--format" default:"json" help:"json/csv/mbox/xml (SMS Backup & Restore)"`
+		Folder string `validate:"oneof=inbox sent drafts all" arg:"--folder" default:"all" help:"inbox/sent/drafts/all"`
+		Output string `arg:"-o,--output" help:"File to write, stdout by default"`
+	}
+
+	SMSImportArgs struct {
+		File   string `validate:"file" arg:"positional,required" help:"File written by export"`
+		Format string `validate:"omitempty,oneof=json csv mbox xml" arg:"-f,--format" help:"json/csv/mbox/xml, guessed from the extension by default"`
+		DryRun bool   `arg:"--dry-run" help:"Only print the messages"`
+	}
+
+	SMSCapacityArgs struct {
+	}
+
+	SMSPruneArgs struct {
+		Threshold *int `validate:"omitempty,gte=1,lte=100" arg:"--threshold" help:"Percent of storage to keep used at most, sms.prune.threshold by default"`
+		Archive   bool `arg:"--archive" help:"Archive messages before deleting them, also enabled by sms.prune.archive"`
+		DryRun    bool `arg:"--dry-run" help:"Only show what would be deleted"`
+	}
+
+	SMSScheduleArgs struct {
+		List        *SMSScheduleListArgs   `validate:"-" arg:"subcommand:list" help:"List scheduled messages and their runs"`
+		Cancel      *SMSScheduleCancelArgs `validate:"-" arg:"subcommand:cancel" help:"Cancel scheduled messages"`
+		PhoneNumber string                 `arg:"-p,--phone" help:"Receiver's phone number, national ones use phone.region"`
+		Message     string                 `validate:"excluded_with=File,omitempty,utf8" arg:"-m,--msg" help:"Message to be sent, \"-\" to read it from stdin"`
+		File        string                 `validate:"omitempty,file" arg:"--file" help:"Read message from a file"`
+		Encoding    string                 `validate:"oneof=auto gsm7 ucs2" arg:"--encoding" default:"auto" help:"Message encoding: auto/gsm7/ucs2"`
+		At          string                 `validate:"excluded_with=Cron" arg:"--at" help:"Send once at this time or after this duration, e.g. 2026-11-01T09:00"`
+		Cron        string                 `arg:"--cron" help:"Send on a cron schedule, e.g. \"0 9 * * MON\""`
+	}
+
+	SMSScheduleListArgs struct {
+	}
+
+	SMSScheduleCancelArgs struct {
+		IDs []string `arg:"positional,required" help:"IDs of scheduled messages"`
+	}
+
+	SMSOutboxArgs struct {
+		List  *SMSOutboxListArgs  `validate:"-" arg:"subcommand:list" help:"List queued and failed messages"`
+		Retry *SMSOutboxRetryArgs `validate:"-" arg:"subcommand:retry" help:"Queue failed messages again"`
+		Purge *SMSOutboxPurgeArgs `validate:"-" arg:"subcommand:purge" help:"Remove messages from the outbox"`
+	}
+
+	SMSOutboxListArgs struct {
+	}
+
+	SMSOutboxRetryArgs struct {
+		IDs []string `arg:"positional" help:"IDs of messages, all failed ones by default"`
+	}
+
+	SMSOutboxPurgeArgs struct {
+		IDs []string `validate:"excluded_with=All" arg:"positional" help:"IDs of messages, all failed ones by default"`
+		All bool     `arg:"--all" help:"Remove every message, including queued ones"`
+	}
+
+	SMSDeleteArgs struct {
+		IDs     []string `validate:"required_without_all=AllRead All,excluded_with=AllRead All" arg:"--id" help:"IDs of messages to delete"`
+		AllRead bool     `validate:"excluded_with=All" arg:"--all-read" help:"Delete all read messages"`
+		All     bool     `arg:"--all" help:"Delete all messages"`
+	}
+
+	SMSMarkReadArgs struct {
+		IDs []string `validate:"required_without=All,excluded_with=All" arg:"--id" help:"IDs of messages to mark as read"`
+		All bool     `arg:"--all" help:"Mark all messages as read"`
+	}
+
+	APNActionArgs struct {
+		List       *APNListArgs    `validate:"-" arg:"subcommand:list" help:"List APN profiles"`
+		Add        *APNProfileArgs `validate:"-" arg:"subcommand:add" help:"Add APN profile"`
+		Edit       *APNEditArgs    `validate:"-" arg:"subcommand:edit" help:"Edit APN profile"`
+		SetDefault *APNIndexArgs   `validate:"-" arg:"subcommand:set-default" help:"Set default APN profile"`
+		Delete     *APNIndexArgs   `validate:"-" arg:"subcommand:delete" help:"Delete APN profile"`
+	}
+
+	APNListArgs struct {
+	}
+
+	APNProfileArgs struct {
+		Name     string `validate:"required" arg:"-n,--name,required" help:"Profile name"`
+		APN      string `validate:"required" arg:"-a,--apn,required" help:"Access point name"`
+		PDPType  string `validate:"oneof=IPv4 IPv6 IPv4v6" arg:"--pdp" default:"IPv4" help:"PDP type: IPv4/IPv6/IPv4v6"`
+		AuthType string `validate:"oneof=none pap chap" arg:"--auth" default:"none" help:"Authentication type: none/pap/chap"`
+		Username string `arg:"-u,--user" help:"Username"`
+		Password string `arg:"--password" help:"Password"`
+	}
+
+	APNEditArgs struct {
+		Index int `validate:"gte=0" arg:"-i,--index,required" help:"Profile index"`
+		APNProfileArgs
+	}
+
+	APNIndexArgs struct {
+		Index int `validate:"gte=0" arg:"positional,required" help:"Profile index"`
+	}
+
+	SIMActionArgs struct {
+		Status *SIMStatusArgs `validate:"-" arg:"subcommand:status" help:"Show SIM state"`
+		Unlock *SIMUnlockArgs `validate:"-" arg:"subcommand:unlock" help:"Unlock SIM with PIN or PUK"`
+		PIN    *SIMPINArgs    `validate:"-" arg:"subcommand:pin" help:"Manage PIN lock"`
+	}
+
+	SIMStatusArgs struct {
+	}
+
+	SIMUnlockArgs struct {
+		PIN    string `validate:"required_without=PUK,omitempty,numeric,min=4,max=8" arg:"--pin" help:"SIM PIN"`
+		PUK    string `validate:"omitempty,numeric,len=8" arg:"--puk" help:"SIM PUK"`
+		NewPIN string `validate:"required_with=PUK,omitempty,numeric,min=4,max=8" arg:"--new-pin" help:"New PIN to set when unlocking with PUK"`
+	}
+
+	SIMPINArgs struct {
+		Action string `validate:"oneof=enable disable change" arg:"positional,required" help:"enable/disable/change"`
+		PIN    string `validate:"numeric,min=4,max=8" arg:"--pin,required" help:"Current SIM PIN"`
+		NewPIN string `validate:"required_if=Action change,omitempty,numeric,min=4,max=8" arg:"--new-pin" help:"New SIM PIN"`
+	}
+
+	NetworkActionArgs struct {
+		Mode   *NetworkModeArgs   `validate:"-" arg:"subcommand:mode" help:"Show or set preferred network mode"`
+		Bands  *NetworkBandsArgs  `validate:"-" arg:"subcommand:bands" help:"Show, lock or unlock LTE bands"`
+		Scan   *NetworkScanArgs   `validate:"-" arg:"subcommand:scan" help:"Scan for operators"`
+		Select *NetworkSelectArgs `validate:"-" arg:"subcommand:select" help:"Select operator manually or automatically"`
+	}
+
+	NetworkModeArgs struct {
+		Mode string `validate:"omitempty,oneof=auto 4g 3g 2g 4g-preferred 3g-preferred" arg:"positional" help:"auto/4g/3g/2g/4g-preferred/3g-preferred"`
+	}
+
+	NetworkBandsArgs struct {
+		Lock   []int `validate:"excluded_with=Unlock,dive,min=1,max=64" arg:"--lock" help:"LTE bands to lock to"`
+		Unlock bool  `arg:"--unlock" help:"Unlock all LTE bands"`
+	}
+
+	NetworkScanArgs struct {
+	}
+
+	NetworkSelectArgs struct {
+		PLMN string `validate:"required_without=Auto,excluded_with=Auto,omitempty,numeric,min=5,max=6" arg:"positional" help:"Operator MCC+MNC"`
+		RAT  string `validate:"oneof=4g 3g 2g" arg:"--rat" default:"4g" help:"Access technology: 4g/3g/2g"`
+		Auto bool   `arg:"--auto" help:"Go back to automatic operator selection"`
+	}
+
+	UsageArgs struct {
+		Reset     bool          `arg:"--reset" help:"Reset usage counters"`
+		Limit     string        `validate:"excluded_with=NoLimit TimeLimit" arg:"--limit" help:"Set monthly data limit, e.g. 20GB"`
+		TimeLimit time.Duration `validate:"excluded_with=NoLimit,gte=0" arg:"--time-limit" help:"Set monthly connection time limit, e.g. 100h"`
+		Alert     int           `validate:"gte=0,lte=100" arg:"--alert" default:"90" help:"Warn when this percentage of the limit is used"`
+		NoLimit   bool          `arg:"--no-limit" help:"Disable data limit"`
+	}
+
+	DeviceArgs struct {
+		Action  string        `validate:"oneof=reboot poweroff factory-reset" arg:"positional,required" help:"reboot/poweroff/factory-reset"`
+		Yes     bool          `arg:"-y,--yes" help:"Don't ask for confirmation"`
+		Wait    bool          `arg:"--wait" help:"Wait until the modem is back online after reboot or factory-reset"`
+		Timeout time.Duration `validate:"gt=0" arg:"--timeout" default:"3m" help:"How long to wait for the modem"`
+	}
+
+	WiFiActionArgs struct {
+		Status  *WiFiStatusArgs  `validate:"-" arg:"subcommand:status" help:"Show Wi-Fi settings"`
+		Set     *WiFiSetArgs     `validate:"-" arg:"subcommand:set" help:"Change Wi-Fi settings"`
+		Clients *WiFiClientsArgs `validate:"-" arg:"subcommand:clients" help:"List connected clients"`
+		Block   *WiFiBlockArgs   `validate:"-" arg:"subcommand:block" help:"Manage MAC block list"`
+	}
+
+	WiFiStatusArgs struct {
+		ShowPassword bool `arg:"--show-password" help:"Show Wi-Fi passphrase"`
+	}
+
+	WiFiSetArgs struct {
+		SSID      *string `validate:"omitempty,min=1,max=32" arg:"--ssid" help:"Network name"`
+		Password  *string `validate:"omitempty,min=8,max=63,printascii" arg:"--password" help:"WPA passphrase"`
+		Security  *string `validate:"omitempty,oneof=open wpa2 wpa-wpa2 wpa3 wpa2-wpa3" arg:"--security" help:"open/wpa2/wpa-wpa2/wpa3/wpa2-wpa3"`
+		Channel   *int    `validate:"omitempty,gte=0,lte=165" arg:"--channel" help:"Channel, 0 for automatic"`
+		Broadcast *string `validate:"omitempty,oneof=on off" arg:"--broadcast" help:"Broadcast SSID: on/off"`
+		Enabled   *string `validate:"omitempty,oneof=on off" arg:"--enabled" help:"Turn Wi-Fi on/off"`
+	}
+
+	WiFiClientsArgs struct {
+	}
+
+	WiFiBlockArgs struct {
+		Action string   `validate:"oneof=list add remove" arg:"positional,required" help:"list/add/remove"`
+		MACs   []string `validate:"required_unless=Action list,dive,mac" arg:"positional" help:"MAC addresses"`
 	}
 
 	ConnectionArgs struct {
-		Action string `arg:"positional,required" help:"up/down/status" validate:"oneof=up down status"`
+		Action    string  `arg:"positional,required" help:"up/down/status/config" validate:"oneof=up down status config"`
+		AutoDial  *string `arg:"--auto-dial" help:"config: connect automatically on power up: on/off" validate:"omitempty,oneof=on off"`
+		Roaming   *string `arg:"--roaming" help:"config: allow roaming: on/off" validate:"omitempty,oneof=on off"`
+		Reconnect *string `arg:"--reconnect" help:"config: reconnect when the connection drops: on/off" validate:"omitempty,oneof=on off"`
+	}
+
+	DaemonArgs struct {
 	}
 
 	BaseArgs struct {
-		Connection   *ConnectionArgs `validate:"-" arg:"subcommand:conn" help:"Manage cell connection"`
-		SMS          *SMSActionArgs  `validate:"-" arg:"subcommand:sms" help:"Manage SMS"`
-		Host         string          `validate:"omitempty,ipv4|hostname" arg:"--host" help:"Override hostname in config file"`
-		DisableColor bool            `arg:"--plain" help:"Disable color for better software interaction"`
+		Connection   *ConnectionArgs    `validate:"-" arg:"subcommand:conn" help:"Manage cell connection"`
+		SMS          *SMSActionArgs     `validate:"-" arg:"subcommand:sms" help:"Manage SMS"`
+		APN          *APNActionArgs     `validate:"-" arg:"subcommand:apn" help:"Manage APN profiles"`
+		SIM          *SIMActionArgs     `validate:"-" arg:"subcommand:sim" help:"Manage SIM PIN/PUK"`
+		Network      *NetworkActionArgs `validate:"-" arg:"subcommand:network" help:"Manage network mode, bands and operator"`
+		Usage        *UsageArgs         `validate:"-" arg:"subcommand:usage" help:"Show data usage and manage limits"`
+		Device       *DeviceArgs        `validate:"-" arg:"subcommand:device" help:"Reboot, power off or reset the modem"`
+		WiFi         *WiFiActionArgs    `validate:"-" arg:"subcommand:wifi" help:"Manage Wi-Fi hotspot"`
+		Daemon       *DaemonArgs        `validate:"-" arg:"subcommand:daemon" help:"Run background tasks enabled in config"`
+		Host         string             `validate:"omitempty,ipv4|hostname" arg:"--host" help:"Override hostname in config file"`
+		DisableColor bool               `arg:"--plain" help:"Disable color for better software interaction"`
 	}
 )
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"runtime"
+	"strings"
 
 	"github.com/spf13/viper"
 )
@@ -33,17 +34,74 @@ func init() {
 	config.SetDefault("modem.model", "dummy")
 	config.SetDefault("modem.host", "127.0.0.1")
 	config.SetDefault("modem.cmd_ttl", 10)
+	config.SetDefault("modem.time_zone_unit", "hours") // Unit of the zone in message dates, some firmwares use quarters
+
+	config.SetDefault("state.dir", dir+sep+"modem-cli"+sep+"state")
+
+	config.SetDefault("phone.region", "") // Country of national numbers, e.g. DE
+
+	config.SetDefault("sms.prune.enabled", false)
+	config.SetDefault("sms.prune.threshold", 80) // Percent of storage
+	config.SetDefault("sms.prune.archive", false)
+	config.SetDefault("sms.prune.interval", "10m")
+
+	config.SetDefault("sms.schedule.interval", "30s")
+
+	config.SetDefault("sms.outbox.interval", "10s")
+	config.SetDefault("sms.outbox.backoff", "30s")
+	config.SetDefault("sms.outbox.max_backoff", "1h")
+	config.SetDefault("sms.outbox.max_attempts", 10)
+
+	config.SetDefault("gateway.enabled", false)
+	config.SetDefault("gateway.interval", "10s")
+	config.SetDefault("gateway.script_timeout", "1m")
+
+	config.SetDefault("webhook.enabled", false)
+	config.SetDefault("webhook.interval", "10s")
+	config.SetDefault("webhook.timeout", "10s")
+	config.SetDefault("webhook.backoff", "30s")
+	config.SetDefault("webhook.max_backoff", "1h")
+	config.SetDefault("webhook.max_attempts", 0) // Messages are kept until delivered
+
+	config.SetDefault("email.enabled", false)
+	config.SetDefault("email.interval", "30s")
+	config.SetDefault("email.timeout", "30s")
+	config.SetDefault("email.domain", "sms.local") // Emails to <number>@<domain> are sent as SMS
+	config.SetDefault("email.allow", []string{})   // Senders allowed to send SMS, checked by From which can be forged
+	config.SetDefault("email.secret", "")          // Word the subject must contain, required with email.imap.host
+	config.SetDefault("email.smtp.tls", "starttls")
+	config.SetDefault("email.imap.tls", "tls")
+	config.SetDefault("email.imap.mailbox", "INBOX")
+	config.SetDefault("email.backoff", "30s")
+	config.SetDefault("email.max_backoff", "1h")
+	config.SetDefault("email.max_attempts", 0)
 
 	config.SetDefault("logging.general", "error")
 	config.SetDefault("logging.driver", "error")
 	// -- Defaults -- //
 
+	// Defaults are used when there is no config file
 	err = config.ReadInConfig()
-	if err != nil { // Handle errors reading the config file
+	if _, notFound := err.(viper.ConfigFileNotFoundError); err != nil && !notFound {
 		panic(fmt.Errorf("fatal error config file: %w", err))
 	}
 }
 
+// Overrides a value for the rest of the run
+func Set(key string, value any) {
+	config.Set(key, value)
+}
+
+// Returns a section of the config. Unlike viper's Sub, keeps the defaults
 func Sub(name string) *viper.Viper {
-	return config.Sub(name)
+	sub := viper.New()
+
+	prefix := name + "."
+	for _, key := range config.AllKeys() {
+		if strings.HasPrefix(key, prefix) {
+			sub.SetDefault(strings.TrimPrefix(key, prefix), config.Get(key))
+		}
+	}
+
+	return sub
 }
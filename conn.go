@@ -0,0 +1,46 @@
+package main
+
+import (
+	"github.com/brokenCursor/usb-modem-cli/drivers"
+	"github.com/i582/cfmt/cmd/cfmt"
+)
+
+func runConnConfig(modem drivers.BaseModem) error {
+	cell, ok := modem.(drivers.ModemCellPolicy)
+	if !ok {
+		return DriverSupportError{Driver: modem, Function: "connection policy"}
+	}
+
+	policy, err := cell.GetConnPolicy()
+	if err != nil {
+		return err
+	}
+
+	conn := args.Connection
+	if conn.AutoDial != nil || conn.Roaming != nil || conn.Reconnect != nil {
+		if conn.AutoDial != nil {
+			policy.AutoDial = *conn.AutoDial == "on"
+
+			// Auto dial implies reconnecting unless told otherwise
+			policy.Reconnect = policy.AutoDial
+		}
+		if conn.Roaming != nil {
+			policy.Roaming = *conn.Roaming == "on"
+		}
+		if conn.Reconnect != nil {
+			policy.Reconnect = *conn.Reconnect == "on"
+		}
+
+		return cell.SetConnPolicy(*policy)
+	}
+
+	cfmt.Printf("{{Auto dial:}}::cyan %s\n{{Roaming:}}::cyan %s\n{{Reconnect:}}::cyan %s\n", onOff(policy.AutoDial), onOff(policy.Roaming), onOff(policy.Reconnect))
+	return nil
+}
+
+func onOff(b bool) string {
+	if b {
+		return "on"
+	}
+	return "off"
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/brokenCursor/usb-modem-cli/config"
+	"github.com/brokenCursor/usb-modem-cli/drivers"
+	"github.com/i582/cfmt/cmd/cfmt"
+)
+
+// Job the daemon runs periodically
+type daemonTask struct {
+	name     string
+	interval time.Duration
+	run      func() error
+	next     time.Time
+}
+
+var ErrNothingToRun = errors.New("no background tasks are enabled in config")
+
+func runDaemon(modem drivers.BaseModem) error {
+	tasks, err := daemonTasks(modem)
+	if err != nil {
+		return err
+	}
+	if len(tasks) == 0 {
+		return ErrNothingToRun
+	}
+	for _, task := range tasks {
+		if task.interval <= 0 {
+			return fmt.Errorf("%s: interval must be positive", task.name)
+		}
+	}
+
+	for {
+		next := time.Time{}
+		for _, task := range tasks {
+			if !time.Now().Before(task.next) {
+				// Modem may be rebooting, keep running
+				if err := task.run(); err != nil {
+					cfmt.Fprintf(os.Stderr, "{{warning:}}::yellow %s: %v\n", task.name, err)
+				}
+				task.next = time.Now().Add(task.interval)
+			}
+
+			if next.IsZero() || task.next.Before(next) {
+				next = task.next
+			}
+		}
+
+		time.Sleep(time.Until(next))
+	}
+}
+
+// Returns tasks enabled in config
+func daemonTasks(modem drivers.BaseModem) ([]*daemonTask, error) {
+	tasks := []*daemonTask{}
+
+	// Scheduled messages go through the outbox, which is always sent when the driver can
+	if sms, ok := modem.(drivers.ModemSMS); ok {
+		tasks = append(tasks, &daemonTask{
+			name:     "sms schedule",
+			interval: config.Sub("sms.schedule").GetDuration("interval"),
+			run:      func() error { return queueDueSMS() },
+		})
+
+		outbox := retryPolicyFromConfig("sms.outbox")
+		tasks = append(tasks, &daemonTask{
+			name:     "sms outbox",
+			interval: config.Sub("sms.outbox").GetDuration("interval"),
+			run:      func() error { return deliverOutbox(sms, outbox) },
+		})
+	}
+
+	if prune := config.Sub("sms.prune"); prune.GetBool("enabled") {
+		sms, ok := modem.(drivers.ModemSMS)
+		if !ok {
+			return nil, DriverSupportError{Driver: modem, Function: "SMS"}
+		}
+
+		policy := prunePolicyFromConfig()
+		tasks = append(tasks, &daemonTask{
+			name:     "sms prune",
+			interval: prune.GetDuration("interval"),
+			run: func() error {
+				pruned, err := pruneSMS(modem, sms, policy, false)
+				if len(pruned) > 0 {
+					cfmt.Printf("{{Pruned:}}::cyan %d messages\n", len(pruned))
+				}
+				return err
+			},
+		})
+	}
+
+	if gateway := config.Sub("gateway"); gateway.GetBool("enabled") {
+		sms, ok := modem.(drivers.ModemSMS)
+		if !ok {
+			return nil, DriverSupportError{Driver: modem, Function: "SMS"}
+		}
+
+		g, err := newSMSGateway(modem, sms)
+		if err != nil {
+			return nil, err
+		}
+		tasks = append(tasks, &daemonTask{name: "sms gateway", interval: gateway.GetDuration("interval"), run: g.Poll})
+	}
+
+	if webhook := config.Sub("webhook"); webhook.GetBool("enabled") {
+		sms, ok := modem.(drivers.ModemSMS)
+		if !ok {
+			return nil, DriverSupportError{Driver: modem, Function: "SMS"}
+		}
+
+		f, err := newWebhookForwarder(modem, sms)
+		if err != nil {
+			return nil, err
+		}
+		tasks = append(tasks, &daemonTask{name: "webhook", interval: webhook.GetDuration("interval"), run: f.Poll})
+	}
+
+	if email := config.Sub("email"); email.GetBool("enabled") {
+		sms, ok := modem.(drivers.ModemSMS)
+		if !ok {
+			return nil, DriverSupportError{Driver: modem, Function: "SMS"}
+		}
+
+		b, err := newEmailBridge(modem, sms)
+		if err != nil {
+			return nil, err
+		}
+		if err := b.Listen(); err != nil {
+			return nil, err
+		}
+		tasks = append(tasks, &daemonTask{name: "email", interval: email.GetDuration("interval"), run: b.Poll})
+	}
+
+	return tasks, nil
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/alexflint/go-arg"
+	"github.com/brokenCursor/usb-modem-cli/drivers"
+	"github.com/i582/cfmt/cmd/cfmt"
+)
+
+const devicePollInterval = 2 * time.Second
+
+var ErrAborted = errors.New("aborted by user")
+var ErrWaitTimeout = errors.New("modem did not come back online in time")
+var ErrNoRestart = errors.New("modem never went offline, it may not have restarted")
+
+func runDevice(parser *arg.Parser, modem drivers.BaseModem) error {
+	device, ok := modem.(drivers.ModemDevice)
+	if !ok {
+		return DriverSupportError{Driver: modem, Function: "device control"}
+	}
+
+	if err := validate.Struct(args.Device); err != nil {
+		parser.FailSubcommand("Unknown action", "device")
+	}
+	if args.Device.Wait && args.Device.Action == "poweroff" {
+		parser.FailSubcommand("--wait can't be used with poweroff", "device")
+	}
+
+	switch args.Device.Action {
+	case "reboot":
+		if err := device.Reboot(); err != nil {
+			return err
+		}
+	case "poweroff":
+		if !args.Device.Yes && !confirm("The modem will be turned off and can't be turned on remotely. Continue?") {
+			return ErrAborted
+		}
+
+		// Nothing to wait for after that
+		return device.PowerOff()
+	case "factory-reset":
+		if !args.Device.Yes && !confirm("All modem settings will be lost. Continue?") {
+			return ErrAborted
+		}
+
+		if err := device.FactoryReset(); err != nil {
+			return err
+		}
+	}
+
+	if args.Device.Wait {
+		cfmt.Println("Waiting for the modem...")
+		if err := waitForModem(device, args.Device.Timeout); err != nil {
+			return err
+		}
+		cfmt.Println("Status: {{online}}::green|bold")
+	}
+
+	return nil
+}
+
+// Asks a yes/no question on stdin, defaulting to no
+func confirm(question string) bool {
+	cfmt.Printf("{{%s}}::yellow [y/N] ", question)
+
+	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil {
+		return false
+	}
+
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
+
+// Waits for the modem to go offline and come back
+func waitForModem(device drivers.ModemDevice, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+
+	wentDown := false
+	for time.Now().Before(deadline) {
+		time.Sleep(devicePollInterval)
+
+		err := device.Ping()
+		switch {
+		case err != nil:
+			logger.With("err", err).Debug("modem is offline")
+			wentDown = true
+		case wentDown:
+			return nil
+		}
+	}
+
+	if !wentDown {
+		// Either it didn't restart or it was back before the first ping
+		return ErrNoRestart
+	}
+	return ErrWaitTimeout
+}
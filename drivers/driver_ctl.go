@@ -24,25 +24,346 @@ type (
 		DisconnectCell() error
 	}
 
+	ModemCellPolicy interface {
+		ModemCell
+
+		GetConnPolicy() (*ConnPolicy, error)
+		SetConnPolicy(policy ConnPolicy) error
+	}
+
+	// Optional, for modems able to report signal and addresses
+	ModemCellInfo interface {
+		ModemCell
+
+		GetCellInfo() (*CellInfo, error)
+	}
+
 	ModemSMS interface {
 		BaseModem
 
-		SendSMS(phone string, message string) error
+		SendSMS(phone string, message string, opts SMSSendOptions) (string, error) // Returns a reference to the sent message, may be empty without StatusReport
 		ReadAllSMS() ([]SMS, error)
+		ReadSMS(query SMSQuery) ([]SMS, error)
+		IterSMS(query SMSQuery) SMSIterator
+		DeleteSMS(ids ...string) error
+		DeleteAllSMS() error
+		MarkSMSRead(ids ...string) error
+	}
+
+	// Optional, for modems able to cheaply tell if new messages have arrived.
+	// Callers keep the last state they saw and read the inbox once it changes
+	ModemSMSNotify interface {
+		ModemSMS
+
+		GetSMSNotify() (*SMSNotify, error)
+	}
+
+	// Optional, for modems able to track delivery
+	ModemSMSReport interface {
+		ModemSMS
+
+		GetSMSDelivery(ref string) (*SMSDelivery, error)
+	}
+
+	// Optional, for modems able to store messages without sending them
+	ModemSMSStore interface {
+		ModemSMS
+
+		StoreSMS(message SMS) error // Drivers may not keep the folder and read state
+	}
+
+	// Optional, for modems able to tell how full message storage is
+	ModemSMSCapacity interface {
+		ModemSMS
+
+		GetSMSCapacity() (*SMSCapacity, error)
+	}
+
+	// Optional, for modems able to read messages from memories other than their own
+	ModemSMSStorage interface {
+		ModemSMS
+
+		GetSMSStorages() []SMSStorage
+	}
+
+	ModemAPN interface {
+		BaseModem
+
+		ListAPN() ([]APNProfile, error)
+		AddAPN(profile APNProfile) error
+		EditAPN(profile APNProfile) error
+		DeleteAPN(index int) error
+		SetDefaultAPN(index int) error
+	}
+
+	ModemSIM interface {
+		BaseModem
+
+		GetSIMStatus() (*SIMStatus, error)
+		EnterPIN(pin string) error
+		EnterPUK(puk string, newPin string) error
+		SetPINLock(enabled bool, pin string) error
+		ChangePIN(oldPin string, newPin string) error
+	}
+
+	ModemNetwork interface {
+		BaseModem
+
+		GetNetworkMode() (NetworkMode, error)
+		SetNetworkMode(mode NetworkMode) error
+		GetLTEBands() ([]int, error)   // Empty if no bands are locked
+		SetLTEBands(bands []int) error // Empty to unlock all bands
+		ScanOperators() ([]Operator, error)
+		SelectOperator(op Operator) error
+		SelectOperatorAuto() error
+	}
+
+	ModemTraffic interface {
+		BaseModem
+
+		GetTrafficStats() (*TrafficStats, error)
+		GetDataLimit() (*DataLimit, error)
+		SetDataLimit(limit DataLimit) error
+	}
+
+	// Optional, for modems able to reset their counters
+	ModemTrafficReset interface {
+		ModemTraffic
+
+		ResetTrafficStats() error
+	}
+
+	ModemDevice interface {
+		BaseModem
+
+		Ping() error // Succeeds if the modem's API answers
+		Reboot() error
+		PowerOff() error
+		FactoryReset() error
+	}
+
+	ModemWiFi interface {
+		BaseModem
+
+		GetWiFiSettings() (*WiFiSettings, error)
+		SetWiFiSettings(settings WiFiSettings) error
+		ListWiFiClients() ([]WiFiClient, error)
+		GetMACBlockList() ([]string, error)
+		SetMACBlockList(macs []string) error
 	}
 )
 
 type (
 	SMS struct {
-		Time    time.Time
-		Sender  string
-		Message string
+		ID      string    `json:"id"` // Modem's message ID
+		Folder  SMSFolder `json:"folder"`
+		Time    time.Time `json:"time"`
+		Sender  string    `json:"sender"` // Recipient for sent messages and drafts
+		Message string    `json:"message"`
+		Read    bool      `json:"read"`
+		Part    *SMSPart  `json:"part,omitempty"`     // Set for parts of concatenated messages
+		PartIDs []string  `json:"part_ids,omitempty"` // Set once parts are joined, ID is the first of them
+	}
+
+	// Position of a message part, from the concatenation header
+	SMSPart struct {
+		Ref   int `json:"ref"` // Same for all parts of a message
+		Seq   int `json:"seq"` // Starts at 1
+		Total int `json:"total"`
+	}
+
+	// Inbox state which changes when messages arrive
+	SMSNotify struct {
+		Received bool // Modem flagged a new message, other clients may reset it
+		Unread   int
+	}
+
+	// Number of messages stored, by memory
+	SMSCapacity struct {
+		SIMUsed     int
+		SIMTotal    int
+		DeviceUsed  int
+		DeviceTotal int
+	}
+
+	// Radio and WAN details, numbers are 0 when unknown
+	CellInfo struct {
+		Operator    string
+		NetworkType string // As reported by the modem, e.g. LTE
+		SignalBars  int    // 0 to 5
+		RSSI        int    // dBm
+		RSRP        int    // dBm, LTE only
+		RSRQ        int    // dB, LTE only
+		SINR        int    // dB, LTE only
+		IPv4        string
+		IPv6        string
 	}
 
 	// Link statuses
 	LinkStatus struct {
 		State int8 // 0 - down 1 - disconnecting 2 - connecting 3 - up
 	}
+
+	// Cell connection policy
+	ConnPolicy struct {
+		AutoDial  bool // Connect automatically on power up
+		Roaming   bool // Allow connecting while roaming
+		Reconnect bool // Reconnect when the connection drops
+	}
+
+	// APN profile as stored on the modem
+	APNProfile struct {
+		Index    int
+		Name     string
+		APN      string
+		PDPType  string // IPv4, IPv6 or IPv4v6
+		AuthType string // none, pap or chap
+		Username string
+		Password string
+		Preset   bool // Preset profiles can't be edited or deleted
+		Default  bool
+	}
+)
+
+// SIM states
+type SIMState int8
+
+const (
+	SIMUnknown SIMState = iota
+	SIMReady
+	SIMPINRequired
+	SIMPUKRequired
+	SIMAbsent
+)
+
+func (s SIMState) String() string {
+	switch s {
+	case SIMReady:
+		return "ready"
+	case SIMPINRequired:
+		return "PIN required"
+	case SIMPUKRequired:
+		return "PUK required"
+	case SIMAbsent:
+		return "absent"
+	default:
+		return "unknown"
+	}
+}
+
+type SIMStatus struct {
+	State       SIMState
+	PINLock     bool // PIN is requested on power up
+	PINAttempts int  // Remaining PIN attempts, -1 if unknown
+	PUKAttempts int  // Remaining PUK attempts, -1 if unknown
+	ICCID       string
+}
+
+// Traffic counters, all in bytes or bytes per second
+type TrafficStats struct {
+	SessionRX       uint64
+	SessionTX       uint64
+	SessionDuration time.Duration
+	MonthlyRX       uint64
+	MonthlyTX       uint64
+	MonthlyDuration time.Duration
+	RXRate          uint64
+	TXRate          uint64
+}
+
+// Data limit types
+const (
+	DataLimitVolume = "data"
+	DataLimitTime   = "time"
+)
+
+type DataLimit struct {
+	Enabled      bool
+	Type         string        // data or time
+	Volume       uint64        // Bytes per month, for data limits
+	Time         time.Duration // Connection time per month, for time limits
+	AlertPercent int           // Usage percentage to warn at
+}
+
+// Wi-Fi security modes
+const (
+	WiFiSecurityOpen     = "open"
+	WiFiSecurityWPA2     = "wpa2"
+	WiFiSecurityWPAWPA2  = "wpa-wpa2"
+	WiFiSecurityWPA3     = "wpa3"
+	WiFiSecurityWPA2WPA3 = "wpa2-wpa3"
+)
+
+type WiFiSettings struct {
+	Enabled    bool
+	SSID       string
+	Passphrase string
+	Security   string // One of WiFiSecurity*
+	Channel    int    // 0 for automatic
+	Broadcast  bool   // SSID is visible
+}
+
+type WiFiClient struct {
+	MAC      string
+	IP       string
+	Hostname string
+}
+
+// Preferred network modes
+type NetworkMode string
+
+const (
+	NetworkModeAuto        NetworkMode = "auto"
+	NetworkMode4G          NetworkMode = "4g"
+	NetworkMode3G          NetworkMode = "3g"
+	NetworkMode2G          NetworkMode = "2g"
+	NetworkMode4GPreferred NetworkMode = "4g-preferred"
+	NetworkMode3GPreferred NetworkMode = "3g-preferred"
+	NetworkModeUnknown     NetworkMode = "unknown"
+)
+
+// Operator states
+type OperatorState int8
+
+const (
+	OperatorUnknown OperatorState = iota
+	OperatorAvailable
+	OperatorCurrent
+	OperatorForbidden
+)
+
+func (s OperatorState) String() string {
+	switch s {
+	case OperatorAvailable:
+		return "available"
+	case OperatorCurrent:
+		return "current"
+	case OperatorForbidden:
+		return "forbidden"
+	default:
+		return "unknown"
+	}
+}
+
+type Operator struct {
+	Name  string
+	PLMN  string      // MCC + MNC
+	RAT   NetworkMode // One of 4g, 3g and 2g
+	State OperatorState
+}
+
+// APN PDP types
+const (
+	PDPTypeIPv4   = "IPv4"
+	PDPTypeIPv6   = "IPv6"
+	PDPTypeIPv4v6 = "IPv4v6"
+)
+
+// APN authentication types
+const (
+	APNAuthNone = "none"
+	APNAuthPAP  = "pap"
+	APNAuthCHAP = "chap"
 )
 
 var (
@@ -21,7 +21,24 @@ func (e ActionError) Unwrap() error {
 }
 
 func (e ActionError) Error() string {
-	return fmt.Sprintf("action error: %s failed with %e", e.Action, e.Err)
+	return fmt.Sprintf("action error: %s failed with %v", e.Action, e.Err)
+}
+
+// Tells if an action may succeed when retried later, e.g. once the modem is
+// reachable again. Errors caused by the request itself are never transient
+func IsTransient(err error) bool {
+	var action ActionError
+	if !errors.As(err, &action) {
+		return false
+	}
+
+	for _, permanent := range []error{ErrSMSTooLong, ErrNotGSM7, ErrSMSNotFound, ErrUnsupportedMode, ErrUnsupportedPolicy} {
+		if errors.Is(err, permanent) {
+			return false
+		}
+	}
+
+	return true
 }
 
 // -- //
@@ -37,3 +54,32 @@ func (e UnmarshalError) Unwrap() error {
 func (e UnmarshalError) Error() string {
 	return "failed to unmarshal response"
 }
+
+// APN Errors
+var ErrAPNNotFound = errors.New("APN profile does not exist")
+var ErrAPNPreset = errors.New("preset APN profiles can't be modified")
+var ErrAPNFull = errors.New("no free APN profile slots")
+
+// SIM Errors
+var ErrSIMPINRequired = errors.New("SIM is locked, PIN required")
+var ErrSIMPUKRequired = errors.New("SIM is blocked, PUK required")
+var ErrSIMAbsent = errors.New("no SIM card detected")
+
+// Network Errors
+var ErrUnsupportedMode = errors.New("network mode is not supported by the modem")
+var ErrScanTimeout = errors.New("operator scan timed out")
+var ErrScanFailed = errors.New("operator scan failed")
+
+// Wi-Fi Errors
+var ErrWiFiOff = errors.New("Wi-Fi is off, turn it on to change SSID settings")
+
+// Connection policy Errors
+var ErrUnsupportedPolicy = errors.New("connection policy is not supported by the modem")
+
+// Phone Errors
+var ErrInvalidPhone = errors.New("invalid phone number")
+
+// SMS Errors
+var ErrSMSNotFound = errors.New("message does not exist")
+var ErrSMSTooLong = errors.New("message is too long")
+var ErrNotGSM7 = errors.New("message has characters outside of the GSM-7 alphabet")
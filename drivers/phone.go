@@ -0,0 +1,91 @@
+package drivers
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	cfg "github.com/brokenCursor/usb-modem-cli/config"
+	"github.com/nyaruka/phonenumbers"
+)
+
+// Service numbers like 22222 are passed on as they are
+var shortCodePattern = regexp.MustCompile(`^[1-9][0-9]{2,5}$`)
+
+// Characters people put into numbers to make them readable
+var phoneSeparators = strings.NewReplacer(" ", "", "-", "", ".", "", "/", "", "(", "", ")", "")
+
+// Returns the number in E.164 form. National numbers belong to phone.region,
+// 00 is taken as the international prefix and short codes are kept
+func NormalizePhone(number string) (string, error) {
+	cleaned := phoneSeparators.Replace(strings.TrimSpace(number))
+	switch {
+	case cleaned == "":
+		return "", fmt.Errorf("%w: number is empty", ErrInvalidPhone)
+	case shortCodePattern.MatchString(cleaned):
+		return cleaned, nil
+	case strings.HasPrefix(cleaned, "00"):
+		cleaned = "+" + cleaned[2:]
+	}
+
+	if strings.ContainsFunc(strings.TrimPrefix(cleaned, "+"), func(r rune) bool { return r < '0' || r > '9' }) {
+		return "", fmt.Errorf("%w %q: only digits, spaces, dashes and a leading + are allowed", ErrInvalidPhone, number)
+	}
+
+	region := strings.ToUpper(cfg.Sub("phone").GetString("region"))
+	if !strings.HasPrefix(cleaned, "+") {
+		switch {
+		case region == "":
+			return "", fmt.Errorf("%w %q: use +<country code> or 00, or set phone.region for national numbers", ErrInvalidPhone, number)
+		case !phonenumbers.GetSupportedRegions()[region]:
+			return "", fmt.Errorf("unknown phone.region %q, use a two letter country code like DE", region)
+		}
+	}
+
+	parsed, err := phonenumbers.Parse(cleaned, region)
+	if err != nil {
+		return "", fmt.Errorf("%w %q: %v", ErrInvalidPhone, number, err)
+	}
+
+	switch phonenumbers.IsPossibleNumberWithReason(parsed) {
+	case phonenumbers.IS_POSSIBLE:
+	case phonenumbers.INVALID_COUNTRY_CODE:
+		return "", fmt.Errorf("%w %q: unknown country code +%d", ErrInvalidPhone, number, parsed.GetCountryCode())
+	case phonenumbers.TOO_SHORT:
+		return "", fmt.Errorf("%w %q: too short for %s", ErrInvalidPhone, number, phoneCountry(parsed))
+	case phonenumbers.TOO_LONG:
+		return "", fmt.Errorf("%w %q: too long for %s", ErrInvalidPhone, number, phoneCountry(parsed))
+	default:
+		return "", fmt.Errorf("%w %q: wrong length for %s, an area code may be missing", ErrInvalidPhone, number, phoneCountry(parsed))
+	}
+
+	return phonenumbers.Format(parsed, phonenumbers.E164), nil
+}
+
+// Normalises numbers for display, leaving names like "Bank" untouched
+func DisplayPhone(sender string) string {
+	if normalized, err := NormalizePhone(sender); err == nil {
+		return normalized
+	}
+	return sender
+}
+
+// Tells if two senders are the same. Numbers are compared in E.164 form, as the
+// modem may store them in national form, which needs phone.region. Names and
+// short codes have to match exactly, ignoring case
+func SamePhone(a string, b string) bool {
+	x, errA := NormalizePhone(a)
+	y, errB := NormalizePhone(b)
+	if errA != nil || errB != nil {
+		return strings.EqualFold(a, b)
+	}
+
+	return x == y
+}
+
+func phoneCountry(number *phonenumbers.PhoneNumber) string {
+	if region := phonenumbers.GetRegionCodeForCountryCode(int(number.GetCountryCode())); region != "ZZ" {
+		return region
+	}
+	return fmt.Sprintf("+%d", number.GetCountryCode())
+}
@@ -0,0 +1,369 @@
+package drivers
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf16"
+
+	"github.com/warthog618/sms/encoding/gsm7"
+)
+
+// SMS folders
+type SMSFolder string
+
+const (
+	SMSFolderAll    SMSFolder = "all"
+	SMSFolderInbox  SMSFolder = "inbox"
+	SMSFolderSent   SMSFolder = "sent"
+	SMSFolderDrafts SMSFolder = "drafts"
+)
+
+// Memories messages are kept in
+type SMSStorage string
+
+const (
+	SMSStorageDevice SMSStorage = "device"
+	SMSStorageSIM    SMSStorage = "sim"
+)
+
+// SMS encodings
+type SMSEncoding string
+
+const (
+	SMSEncodingAuto SMSEncoding = "auto"
+	SMSEncodingGSM7 SMSEncoding = "gsm7"
+	SMSEncodingUCS2 SMSEncoding = "ucs2"
+)
+
+type SMSSendOptions struct {
+	Encoding     SMSEncoding // Detected from the message if empty or auto
+	StatusReport bool        // Request a delivery report
+}
+
+// Delivery states
+type SMSDeliveryState int8
+
+const (
+	SMSPending SMSDeliveryState = iota
+	SMSDelivered
+	SMSFailed
+	SMSExpired
+	SMSUnknown // No message to track
+)
+
+func (s SMSDeliveryState) String() string {
+	switch s {
+	case SMSDelivered:
+		return "delivered"
+	case SMSFailed:
+		return "failed"
+	case SMSExpired:
+		return "expired"
+	case SMSUnknown:
+		return "unknown"
+	default:
+		return "pending"
+	}
+}
+
+// Reports whether the state won't change anymore
+func (s SMSDeliveryState) Final() bool {
+	return s != SMSPending
+}
+
+type SMSDelivery struct {
+	Ref   string
+	State SMSDeliveryState
+	Time  time.Time // Time of the status report, zero while pending
+}
+
+// Maps a TP-Status value (3GPP TS 23.040) to a delivery state
+func DeliveryStateFromTPStatus(status int) SMSDeliveryState {
+	switch {
+	case status < 0x20:
+		return SMSDelivered
+	case status < 0x40:
+		// Temporary error, the SMSC keeps trying
+		return SMSPending
+	case status == 0x46 || status == 0x66:
+		return SMSExpired
+	default:
+		return SMSFailed
+	}
+}
+
+// Picks GSM-7 if all characters are in its default alphabet or extension table, UCS-2 otherwise
+func DetectSMSEncoding(message string) SMSEncoding {
+	if _, err := gsm7.Encode([]byte(message)); err != nil {
+		return SMSEncodingUCS2
+	}
+	return SMSEncodingGSM7
+}
+
+// Characters per segment
+const (
+	gsm7SingleLen = 160
+	gsm7PartLen   = 153 // 7 septets go to the concatenation header
+	ucs2SingleLen = 70
+	ucs2PartLen   = 67
+)
+
+// Length of a message once encoded
+type SMSInfo struct {
+	Encoding  SMSEncoding
+	Length    int // Septets for GSM-7, UTF-16 code units for UCS-2
+	Segments  int
+	Remaining int // Characters left in the last segment
+}
+
+// Counts segments the message will be sent in
+func CountSMS(message string, encoding SMSEncoding) (*SMSInfo, error) {
+	if encoding == "" || encoding == SMSEncodingAuto {
+		encoding = DetectSMSEncoding(message)
+	}
+
+	// Size of each character, extension and non-BMP characters take two
+	sizes := []int{}
+	for _, r := range message {
+		switch encoding {
+		case SMSEncodingUCS2:
+			sizes = append(sizes, len(utf16.Encode([]rune{r})))
+		default:
+			septets, err := gsm7.Encode([]byte(string(r)))
+			if err != nil {
+				return nil, fmt.Errorf("%w: %w", ErrNotGSM7, err)
+			}
+			sizes = append(sizes, len(septets))
+		}
+	}
+
+	single, part := gsm7SingleLen, gsm7PartLen
+	if encoding == SMSEncodingUCS2 {
+		single, part = ucs2SingleLen, ucs2PartLen
+	}
+
+	info := &SMSInfo{Encoding: encoding, Segments: 1}
+	for _, size := range sizes {
+		info.Length += size
+	}
+
+	if info.Length <= single {
+		info.Remaining = single - info.Length
+		return info, nil
+	}
+
+	// Characters can't be split between segments
+	used := 0
+	for _, size := range sizes {
+		if used+size > part {
+			info.Segments++
+			used = 0
+		}
+		used += size
+	}
+	info.Remaining = part - used
+
+	return info, nil
+}
+
+// Filters for reading SMS. Zero values match everything
+type SMSQuery struct {
+	Folder     SMSFolder  // Inbox if empty
+	Storage    SMSStorage // Device if empty, only drivers implementing ModemSMSStorage read the SIM
+	UnreadOnly bool
+	Sender     string // Number or name, compared with SamePhone
+	Since      time.Time
+	Until      time.Time
+	Limit      int // Newest messages first
+}
+
+// Reports whether the message passes the query's filters, except Limit
+func (q *SMSQuery) Match(message *SMS) bool {
+	folder := q.Folder
+	if folder == "" {
+		folder = SMSFolderInbox
+	}
+
+	switch {
+	case folder != SMSFolderAll && message.Folder != folder:
+		return false
+	case q.UnreadOnly && message.Read:
+		return false
+	case q.Sender != "" && !SamePhone(message.Sender, q.Sender):
+		return false
+	case !q.Since.IsZero() && message.Time.Before(q.Since):
+		return false
+	case !q.Until.IsZero() && message.Time.After(q.Until):
+		return false
+	}
+
+	return true
+}
+
+// Orders message IDs, numerically where both are numbers
+func CompareSMSID(a string, b string) int {
+	x, errA := strconv.ParseUint(a, 10, 64)
+	y, errB := strconv.ParseUint(b, 10, 64)
+	if errA != nil || errB != nil {
+		return strings.Compare(a, b)
+	}
+
+	return cmp.Compare(x, y)
+}
+
+// Iterates over messages matching a query, newest first. Usage:
+//
+//	it := modem.IterSMS(query)
+//	for it.Next() {
+//		message := it.SMS()
+//	}
+//	if err := it.Err(); err != nil {
+//	}
+type SMSIterator interface {
+	Next() bool // Advances to the next message, false when done or on error
+	SMS() *SMS  // Current message, valid until the next call to Next
+	Err() error
+}
+
+// Reads all remaining messages from the iterator
+func CollectSMS(it SMSIterator) ([]SMS, error) {
+	messages := []SMS{}
+	for it.Next() {
+		messages = append(messages, *it.SMS())
+	}
+
+	if err := it.Err(); err != nil {
+		return nil, err
+	}
+	return messages, nil
+}
+
+// Client-side filtering for drivers without native support
+func FilterSMS(messages []SMS, query SMSQuery) []SMS {
+	filtered := []SMS{}
+	for i := range messages {
+		if query.Limit > 0 && len(filtered) >= query.Limit {
+			break
+		}
+
+		if query.Match(&messages[i]) {
+			filtered = append(filtered, messages[i])
+		}
+	}
+
+	return filtered
+}
+
+type (
+	smsPartKey struct {
+		sender string
+		ref    int
+		total  int
+	}
+
+	smsReassembler struct {
+		it       SMSIterator
+		groups   map[smsPartKey][]SMS
+		order    []smsPartKey
+		leftover []SMS
+		current  SMS
+		drained  bool
+	}
+)
+
+// Wraps an iterator to join parts of concatenated messages. Parts are held back
+// until all of them are read, incomplete ones come last as they are
+func ReassembleSMS(it SMSIterator) SMSIterator {
+	return &smsReassembler{it: it, groups: map[smsPartKey][]SMS{}}
+}
+
+func (r *smsReassembler) Next() bool {
+	for !r.drained {
+		if !r.it.Next() {
+			r.drained = true
+
+			// Whatever is left won't be completed
+			for _, k := range r.order {
+				r.leftover = append(r.leftover, r.groups[k]...)
+			}
+			break
+		}
+
+		message := *r.it.SMS()
+		if message.Part == nil {
+			r.current = message
+			return true
+		}
+
+		k := smsPartKey{message.Sender, message.Part.Ref, message.Part.Total}
+		if _, ok := r.groups[k]; !ok {
+			r.order = append(r.order, k)
+		}
+		r.groups[k] = append(r.groups[k], message)
+
+		if countSMSParts(r.groups[k]) == k.total {
+			r.current = joinSMSParts(r.groups[k])
+			delete(r.groups, k)
+			r.order = slices.DeleteFunc(r.order, func(o smsPartKey) bool { return o == k })
+			return true
+		}
+	}
+
+	if len(r.leftover) == 0 {
+		return false
+	}
+
+	r.current = r.leftover[0]
+	r.leftover = r.leftover[1:]
+	return true
+}
+
+func (r *smsReassembler) SMS() *SMS {
+	return &r.current
+}
+
+func (r *smsReassembler) Err() error {
+	return r.it.Err()
+}
+
+// Number of different parts, a part may be stored twice
+func countSMSParts(parts []SMS) int {
+	seqs := map[int]bool{}
+	for i := range parts {
+		seqs[parts[i].Part.Seq] = true
+	}
+	return len(seqs)
+}
+
+func joinSMSParts(parts []SMS) SMS {
+	sort.SliceStable(parts, func(i, j int) bool { return parts[i].Part.Seq < parts[j].Part.Seq })
+
+	// First part carries the ID and time of the message, duplicates only add their IDs
+	joined := parts[0]
+	joined.Part = nil
+	joined.PartIDs = []string{parts[0].ID}
+	for i, part := range parts[1:] {
+		joined.PartIDs = append(joined.PartIDs, part.ID)
+		if part.Part.Seq == parts[i].Part.Seq {
+			continue
+		}
+
+		joined.Message += part.Message
+		joined.Read = joined.Read && part.Read
+	}
+
+	return joined
+}
+
+// IDs of every stored part of the message
+func (s *SMS) IDs() []string {
+	if len(s.PartIDs) > 0 {
+		return s.PartIDs
+	}
+	return []string{s.ID}
+}
@@ -1,7 +1,6 @@
 package drivers
 
 import (
-	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -14,16 +13,19 @@ import (
 
 	cfg "github.com/brokenCursor/usb-modem-cli/config"
 	"github.com/spf13/viper"
-	"github.com/warthog618/sms/encoding/gsm7"
-	"github.com/warthog618/sms/encoding/ucs2"
 )
 
+// Longest concatenated message the web UI allows
+const zteMaxSegments = 5
+
 // DO NOT USE DIRECTLY
 type (
 	zte8810ft struct {
 		httpClient *http.Client
 		logger     *slog.Logger
 		config     *viper.Viper
+		reports    []zteReport // Status reports as of the last read
+		zoneUnits  float64     // Time zone units per hour in message dates
 	}
 
 	result struct {
@@ -33,17 +35,6 @@ type (
 	pppConnected struct {
 		Connected string `json:"ppp_status"`
 	}
-
-	rawIncomingSMS struct {
-		Messages []struct {
-			ID           string `json:"id"`
-			Source       string `json:"number"`
-			Content      string `json:"content"`
-			Tag          string `json:"tag"`
-			Date         string `json:"date"`
-			DraftGroupID string `json:"draft_group_id"`
-		} `json:"messages"`
-	}
 )
 
 func init() {
@@ -51,6 +42,9 @@ func init() {
 }
 
 func newZTE8810FT(config *viper.Viper, logger *slog.Logger) (BaseModem, error) {
+	// Don't let requests to a dead modem hang forever
+	httpClient := &http.Client{Timeout: time.Duration(config.GetInt("cmd_ttl")) * time.Second}
+
 	if config.IsSet("iface") {
 		ifaceName := config.GetString("iface")
 		logger.With("iface_name", ifaceName).Debug("NIC has been specified")
@@ -68,11 +62,16 @@ func newZTE8810FT(config *viper.Viper, logger *slog.Logger) (BaseModem, error) {
 			return nil, err
 		}
 
-		return &zte8810ft{logger: logger.With("modem", "ZTE8810FT"), config: config, httpClient: &http.Client{Transport: transport}}, nil
-	} else {
-		return &zte8810ft{logger: logger.With("modem", "ZTE8810FT"), config: config, httpClient: &http.Client{}}, nil
+		httpClient.Transport = transport
 	}
 
+	// Firmwares don't say which unit they use for time zones
+	zoneUnits := map[string]float64{"hours": 1, "quarters": 4}[config.GetString("time_zone_unit")]
+	if zoneUnits == 0 {
+		return nil, cfg.ConfigError{Key: "time_zone_unit", Value: config.GetString("time_zone_unit"), Err: cfg.ErrInvalidValue}
+	}
+
+	return &zte8810ft{logger: logger.With("modem", "ZTE8810FT"), config: config, httpClient: httpClient, zoneUnits: zoneUnits}, nil
 }
 
 func (m *zte8810ft) getBaseURL(path string) *url.URL {
@@ -91,135 +90,127 @@ func (m *zte8810ft) getNewRequest(method string, url *url.URL, headers http.Head
 	return
 }
 
-func (m *zte8810ft) GetModel() string {
-	return "ZTE 8810FT"
-}
-
-func (m *zte8810ft) ConnectCell() error {
-	// Prepare everything to make a request
-	u := m.getBaseURL("/goform/goform_set_cmd_process")
+// Queries goform_get_cmd_process for cmds and unmarshals the response into v
+func (m *zte8810ft) getCmd(action string, cmds []string, extra url.Values, v any) error {
+	// Build URL
+	u := m.getBaseURL("/goform/goform_get_cmd_process")
 	query := u.Query()
-	query.Add("goformId", "CONNECT_NETWORK")
+	query.Add("isTest", "false")
+	query.Add("cmd", strings.Join(cmds, ","))
+	if len(cmds) > 1 {
+		query.Add("multi_data", "1")
+	}
+	for key, values := range extra {
+		for _, value := range values {
+			query.Add(key, value)
+		}
+	}
+	query.Add("_", strconv.FormatInt((time.Now().UnixMilli)(), 10))
 	u.RawQuery = query.Encode()
 
-	// Create request
 	request, err := m.getNewRequest("GET", u, http.Header{}, nil)
 	if err != nil {
-		return ActionError{Action: "connect", Err: err}
+		return ActionError{Action: action, Err: err}
 	}
-	m.logger.Debug("request", request.URL.String(), nil)
+	m.logger.With("request", request.URL.String()).Debug("request")
 
 	resp, err := m.httpClient.Do(request)
 
 	// Process errors
 	switch {
 	case err != nil:
-		return ActionError{Action: "connect", Err: err}
+		return ActionError{Action: action, Err: err}
 	case resp.StatusCode != 200:
-		return ActionError{Action: "connect", Err: fmt.Errorf("response status %d", resp.StatusCode)}
+		return ActionError{Action: action, Err: fmt.Errorf("response status %d", resp.StatusCode)}
 	}
 
 	// Read the response
 	defer resp.Body.Close()
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return ErrUnknown
+		return ActionError{Action: action, Err: err}
 	}
 
-	result := new(result)
-	if err := json.Unmarshal(body, result); err != nil {
-		return ActionError{Action: "connect", Err: UnmarshalError{RawData: &body, Err: err}}
-	}
-
-	if result.Result != "success" {
-		return ActionError{Action: "connect", Err: fmt.Errorf("result: %s", result.Result)}
+	if err := json.Unmarshal(body, v); err != nil {
+		return ActionError{Action: action, Err: UnmarshalError{RawData: &body, Err: err}}
 	}
 
 	return nil
 }
 
-func (m *zte8810ft) DisconnectCell() error {
-	// 	GET /goform/goform_set_cmd_process?goformId=DISCONNECT_NETWORK
-	// Prepare everything to make a request
+// Posts goformId with form to goform_set_cmd_process and checks the result
+func (m *zte8810ft) setCmd(action string, goformId string, form url.Values) error {
 	u := m.getBaseURL("/goform/goform_set_cmd_process")
-	query := u.Query()
-	query.Add("goformId", "DISCONNECT_NETWORK")
-	u.RawQuery = query.Encode()
+
+	// Build body
+	query := url.Values{}
+	query.Add("isTest", "false")
+	query.Add("goformId", goformId)
+	for key, values := range form {
+		for _, value := range values {
+			query.Add(key, value)
+		}
+	}
+	encoded := query.Encode()
 
 	// Create request
-	request, err := m.getNewRequest("GET", u, http.Header{}, nil)
+	request, err := m.getNewRequest("POST", u, http.Header{
+		"Content-Type": {"application/x-www-form-urlencoded; charset=UTF-8"}}, strings.NewReader(encoded))
 	if err != nil {
-		return ActionError{Action: "disconnect", Err: err}
+		return ActionError{Action: action, Err: err}
 	}
-
-	m.logger.Debug("request", request.URL.String(), nil)
+	m.logger.With("url", request.URL.String(), "body", encoded).Debug("request")
 
 	resp, err := m.httpClient.Do(request)
+
 	// Process errors
 	switch {
 	case err != nil:
-		return ActionError{Action: "disconnect", Err: err}
+		return ActionError{Action: action, Err: err}
 	case resp.StatusCode != 200:
-		return ActionError{Action: "disconnect", Err: fmt.Errorf("response status %d", resp.StatusCode)}
+		return ActionError{Action: action, Err: fmt.Errorf("response status %d", resp.StatusCode)}
 	}
 
 	// Read the response
 	defer resp.Body.Close()
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return ErrUnknown
+		return ActionError{Action: action, Err: err}
 	}
 
 	result := new(result)
 	if err := json.Unmarshal(body, result); err != nil {
-		return ActionError{Action: "disconnect", Err: UnmarshalError{RawData: &body, Err: err}}
+		return ActionError{Action: action, Err: UnmarshalError{RawData: &body, Err: err}}
 	}
 
 	if result.Result != "success" {
-		return ActionError{Action: "disconnect", Err: fmt.Errorf("result: %s", result.Result)}
+		return ActionError{Action: action, Err: fmt.Errorf("result: %s", result.Result)}
 	}
 
 	return nil
 }
 
-func (m *zte8810ft) GetCellConnStatus() (*LinkStatus, error) {
-	// Build URL
-	u := m.getBaseURL("/goform/goform_get_cmd_process")
-	query := u.Query()
-	query.Add("isTest", "False")
-	query.Add("cmd", "ppp_status")
-	query.Add("multi_data", "1")
-	query.Add("sms_received_flag_flag", "0")
-	query.Add("sts_received_flag_flag", "0")
-	query.Add("_", strconv.FormatInt((time.Now().UnixMilli)(), 10))
-	u.RawQuery = query.Encode()
-
-	request, err := m.getNewRequest("GET", u, http.Header{}, nil)
-	if err != nil {
-		return nil, ActionError{Action: "status", Err: err}
-	}
-	m.logger.Debug("request", request.URL.String(), nil)
+func (m *zte8810ft) GetModel() string {
+	return "ZTE 8810FT"
+}
 
-	resp, err := m.httpClient.Do(request)
+func (m *zte8810ft) ConnectCell() error {
+	return m.setCmd("connect", "CONNECT_NETWORK", nil)
+}
 
-	// Process errors
-	switch {
-	case err != nil:
-		return nil, ActionError{Action: "status", Err: err}
-	case resp.StatusCode != 200:
-		return nil, ActionError{Action: "status", Err: fmt.Errorf("response status %d", resp.StatusCode)}
-	}
+func (m *zte8810ft) DisconnectCell() error {
+	return m.setCmd("disconnect", "DISCONNECT_NETWORK", nil)
+}
 
-	// Read the response
-	defer resp.Body.Close()
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, ErrUnknown
-	}
+func (m *zte8810ft) GetCellConnStatus() (*LinkStatus, error) {
+	// Keeps the web UI's new message flags as they are
+	extra := url.Values{}
+	extra.Add("sms_received_flag_flag", "0")
+	extra.Add("sts_received_flag_flag", "0")
 
 	result := new(pppConnected)
-	if err := json.Unmarshal(body, result); err != nil {
-		return nil, ActionError{Action: "status", Err: UnmarshalError{RawData: &body, Err: err}}
+	if err := m.getCmd("status", []string{"ppp_status"}, extra, result); err != nil {
+		return nil, err
 	}
 
 	// Process the result
@@ -238,151 +229,45 @@ func (m *zte8810ft) GetCellConnStatus() (*LinkStatus, error) {
 	}
 }
 
-func (m *zte8810ft) SendSMS(phone string, message string) error {
-	// Encode message into GSM-7 as byte array
-	encodedMsg, err := gsm7.Encode([]byte(message))
-	if err != nil {
-		return ActionError{Action: "sms send", Err: err}
-	}
-
-	// Format into proprietary two byte format: 1122 (0074)
-	formattedMsg := ""
-	for _, hex := range encodedMsg {
-		formattedMsg += fmt.Sprintf("00%X", hex)
-	}
-	u := m.getBaseURL("/goform/goform_set_cmd_process")
-
-	// Build body
-	query := u.Query()
-	query.Add("goformId", "SEND_SMS")
-	query.Add("ID", "-1")
-	query.Add("encode_type", "GSM7_default")
-	query.Add("Number", phone)
-	query.Add("MessageBody", formattedMsg)
-
-	// Build send timestamp
-	t := time.Now()
-	if _, tz := t.Zone(); tz >= 0 {
-		query.Add("sms_time", t.Format("06;01;02;15;04;05;+")+strconv.Itoa(tz/3600))
-	} else {
-		query.Add("sms_time", t.Format("06;01;02;15;04;05;")+strconv.Itoa(tz/3600))
-	}
-
-	// Some Go-level string manipulation
-	encoded := query.Encode()
-	queryReader := strings.NewReader(encoded)
-
-	// Create request
-	request, err := m.getNewRequest("POST", u, http.Header{
-		"Content-Type": {"application/x-www-form-urlencoded; charset=UTF-8"}}, queryReader)
-
+func (m *zte8810ft) SendSMS(phone string, message string, opts SMSSendOptions) (string, error) {
+	info, err := CountSMS(message, opts.Encoding)
 	if err != nil {
-		return ActionError{Action: "sms send", Err: err}
+		return "", ActionError{Action: "sms send", Err: err}
 	}
-	m.logger.Debug("url", request.URL.String(), "body", encoded, nil)
-
-	resp, err := m.httpClient.Do(request)
-	request.Close = true
 
-	// Process errors
-	switch {
-	case err != nil:
-		return ActionError{Action: "sms send", Err: err}
-	case resp.StatusCode != 200:
-		return ActionError{Action: "sms send", Err: fmt.Errorf("response status %d", resp.StatusCode)}
-	}
-
-	// Read the response
-	defer resp.Body.Close()
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return ErrUnknown
+	// The modem splits long messages into concatenated parts itself
+	if info.Segments > zteMaxSegments {
+		return "", ActionError{Action: "sms send", Err: fmt.Errorf("%w: %d parts, at most %d are supported", ErrSMSTooLong, info.Segments, zteMaxSegments)}
 	}
 
-	result := new(result)
-	if err := json.Unmarshal(body, result); err != nil {
-		return ActionError{Action: "sms send", Err: UnmarshalError{RawData: &body, Err: err}}
-	}
-
-	if result.Result != "success" {
-		return ActionError{Action: "sms send", Err: fmt.Errorf("result: %s", result.Result)}
+	if opts.StatusReport {
+		if err := m.enableStatusReport(); err != nil {
+			return "", err
+		}
 	}
 
-	return nil
-}
-
-func (m *zte8810ft) ReadAllSMS() ([]SMS, error) {
-	// http://10.96.170.1/goform/goform_get_cmd_process?cmd=sms_data_total&page=0&data_per_page=100&mem_store=1&tags=12&order_by=order+by+id+desc&_=1724578532798
-	// Build query
-	u := m.getBaseURL("/goform/goform_get_cmd_process")
-	query := u.Query()
-	query.Add("cmd", "sms_data_total")
-	query.Add("page", "0")
-	query.Add("data_per_page", "100")
-	query.Add("mem_store", "1")
-	query.Add("tags", "12") // 1 - Received, unread | 2 - Sent | 12 - Received, read + unread | 11 - Drafts (??)
-	query.Add("order_by", "order by id desc")
-	query.Add("_", strconv.FormatInt((time.Now().UnixMilli)(), 10))
-	u.RawQuery = query.Encode()
-
-	request, err := m.getNewRequest("GET", u, http.Header{}, nil)
+	formattedMsg, encodeType, err := zteEncodeMessage(message, info.Encoding)
 	if err != nil {
-		return nil, ActionError{Action: "sms read", Err: err}
+		return "", ActionError{Action: "sms send", Err: err}
 	}
-	m.logger.With("request", request.URL.String()).Debug("request")
-
-	resp, err := m.httpClient.Do(request)
 
-	// Process errors
-	switch {
-	case err != nil:
-		return nil, ActionError{Action: "status", Err: err}
-	case resp.StatusCode != 200:
-		return nil, ActionError{Action: "status", Err: fmt.Errorf("response status %d", resp.StatusCode)}
-	}
+	form := url.Values{}
+	form.Add("ID", "-1")
+	form.Add("encode_type", encodeType)
+	form.Add("Number", phone)
+	form.Add("MessageBody", formattedMsg)
 
-	// Read the response
-	defer resp.Body.Close()
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, ErrUnknown
-	}
-
-	rawSMS := new(rawIncomingSMS)
+	// Build send timestamp
+	t := time.Now().Truncate(time.Second)
+	form.Add("sms_time", zteFormatTime(t, m.zoneUnits))
 
-	err = json.Unmarshal(body, &rawSMS)
-	if err != nil {
-		m.logger.With("body", body).Debug("failed to unmarshal body to struct")
-		return nil, ActionError{Action: "sms read", Err: err}
+	if err := m.setCmd("sms send", "SEND_SMS", form); err != nil {
+		return "", err
 	}
 
-	processedSMS := make([]SMS, len(rawSMS.Messages))
-	for i := range rawSMS.Messages {
-		processedSMS[i].Sender = rawSMS.Messages[i].Source
-
-		// Extract datetime
-		date, err := time.Parse("06,01,02,15,04,05,-07", rawSMS.Messages[i].Date)
-		if err != nil {
-			m.logger.With("id", rawSMS.Messages[i].ID, "raw_date", rawSMS.Messages[i].Date, "err", err).Debug("failed to parse datetime")
-			return nil, ActionError{Action: "sms read", Err: fmt.Errorf("failed to decode message datetime")}
-		}
-		processedSMS[i].Time = date
-
-		// Extract contents
-		rawBytes, err := hex.DecodeString(rawSMS.Messages[i].Content)
-		if err != nil {
-			m.logger.With("id", rawSMS.Messages[i].ID, "raw_content", rawSMS.Messages[i].Content).Debug("failed to parse content")
-			return nil, ActionError{Action: "sms read", Err: fmt.Errorf("failed to parse message content")}
-		}
-
-		runes, err := ucs2.Decode(rawBytes)
-		if err != nil {
-			m.logger.With("id", rawSMS.Messages[i].ID, "raw_content", rawSMS.Messages[i].Content).Debug("failed to decode content")
-			return nil, ActionError{Action: "sms read", Err: fmt.Errorf("failed to decode message content")}
-		}
-
-		processedSMS[i].Message = string(runes)
+	// Looking the message up reads the sent folder, only needed to track it
+	if !opts.StatusReport {
+		return "", nil
 	}
-
-	return processedSMS, nil
+	return m.findSentSMS(phone, message, t), nil
 }
@@ -0,0 +1,219 @@
+package drivers
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+var _ ModemAPN = (*zte8810ft)(nil)
+
+// ZTE keeps up to 20 APN profiles in APN_config0..19 and ipv6_APN_config0..19
+const zteMaxAPN = 20
+
+// Field order of APN_config* values, separated by "($)"
+const (
+	zteAPNProfileName = iota
+	zteAPNName
+	zteAPNSelect
+	zteAPNDial
+	zteAPNAuthMode
+	zteAPNUsername
+	zteAPNPassword
+	zteAPNPDPType
+)
+
+func zteToPDPType(raw string) string {
+	switch raw {
+	case "IPv6":
+		return PDPTypeIPv6
+	case "IPv4v6":
+		return PDPTypeIPv4v6
+	default:
+		return PDPTypeIPv4
+	}
+}
+
+func zteFromPDPType(pdp string) string {
+	switch pdp {
+	case PDPTypeIPv6:
+		return "IPv6"
+	case PDPTypeIPv4v6:
+		return "IPv4v6"
+	default:
+		return "IP"
+	}
+}
+
+func (m *zte8810ft) ListAPN() ([]APNProfile, error) {
+	cmds := []string{"apn_num_preset", "Current_index", "apn_mode"}
+	for i := 0; i < zteMaxAPN; i++ {
+		cmds = append(cmds, fmt.Sprintf("APN_config%d", i), fmt.Sprintf("ipv6_APN_config%d", i))
+	}
+
+	raw := map[string]string{}
+	if err := m.getCmd("apn list", cmds, nil, &raw); err != nil {
+		return nil, err
+	}
+
+	preset, _ := strconv.Atoi(raw["apn_num_preset"])
+	current, err := strconv.Atoi(raw["Current_index"])
+	if err != nil {
+		current = -1
+	}
+
+	profiles := []APNProfile{}
+	for i := 0; i < zteMaxAPN; i++ {
+		config := raw[fmt.Sprintf("APN_config%d", i)]
+		if config == "" {
+			continue
+		}
+
+		fields := strings.Split(config, "($)")
+		if len(fields) <= zteAPNPDPType {
+			m.logger.With("index", i, "raw", config).Debug("malformed APN config")
+			continue
+		}
+
+		profile := APNProfile{
+			Index:   i,
+			Name:    fields[zteAPNProfileName],
+			PDPType: zteToPDPType(fields[zteAPNPDPType]),
+			Preset:  i < preset,
+			Default: i == current,
+		}
+
+		// IPv6-only profiles keep their credentials in the ipv6 config
+		if profile.PDPType == PDPTypeIPv6 {
+			if v6 := strings.Split(raw[fmt.Sprintf("ipv6_APN_config%d", i)], "($)"); len(v6) > zteAPNPDPType {
+				fields = v6
+			}
+		}
+
+		profile.APN = fields[zteAPNName]
+		profile.AuthType = fields[zteAPNAuthMode]
+		profile.Username = fields[zteAPNUsername]
+		profile.Password = fields[zteAPNPassword]
+		profiles = append(profiles, profile)
+	}
+
+	return profiles, nil
+}
+
+func (m *zte8810ft) saveAPN(action string, profile APNProfile) error {
+	authType := profile.AuthType
+	if authType == "" {
+		authType = APNAuthNone
+	}
+
+	form := url.Values{}
+	form.Add("apn_action", "save")
+	form.Add("apn_mode", "manual")
+	form.Add("index", strconv.Itoa(profile.Index))
+	form.Add("profile_name", profile.Name)
+	form.Add("wan_dial", "*99#")
+	form.Add("apn_select", "manual")
+	form.Add("pdp_type", zteFromPDPType(profile.PDPType))
+	form.Add("pdp_select", "auto")
+	form.Add("pdp_addr", "")
+
+	// Same credentials are used for both address families
+	for _, prefix := range []string{"", "ipv6_"} {
+		form.Add(prefix+"wan_apn", profile.APN)
+		form.Add(prefix+"ppp_auth_mode", authType)
+		form.Add(prefix+"ppp_username", profile.Username)
+		form.Add(prefix+"ppp_passtmp", profile.Password)
+		form.Add(prefix+"dns_mode", "auto")
+		form.Add(prefix+"prefer_dns_manual", "")
+		form.Add(prefix+"standby_dns_manual", "")
+	}
+
+	return m.setCmd(action, "APN_PROC_EX", form)
+}
+
+// Returns the profile with the index and fails on preset ones
+func (m *zte8810ft) getEditableAPN(action string, index int) (*APNProfile, error) {
+	profiles, err := m.ListAPN()
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range profiles {
+		if profiles[i].Index != index {
+			continue
+		}
+
+		if profiles[i].Preset {
+			return nil, ActionError{Action: action, Err: ErrAPNPreset}
+		}
+		return &profiles[i], nil
+	}
+
+	return nil, ActionError{Action: action, Err: ErrAPNNotFound}
+}
+
+func (m *zte8810ft) AddAPN(profile APNProfile) error {
+	profiles, err := m.ListAPN()
+	if err != nil {
+		return err
+	}
+
+	// Deleting a profile leaves a gap, so take the first free slot
+	used := map[int]bool{}
+	for _, existing := range profiles {
+		used[existing.Index] = true
+	}
+	for profile.Index = 0; used[profile.Index]; profile.Index++ {
+	}
+	if profile.Index >= zteMaxAPN {
+		return ActionError{Action: "apn add", Err: ErrAPNFull}
+	}
+
+	return m.saveAPN("apn add", profile)
+}
+
+func (m *zte8810ft) EditAPN(profile APNProfile) error {
+	if _, err := m.getEditableAPN("apn edit", profile.Index); err != nil {
+		return err
+	}
+
+	return m.saveAPN("apn edit", profile)
+}
+
+func (m *zte8810ft) DeleteAPN(index int) error {
+	if _, err := m.getEditableAPN("apn delete", index); err != nil {
+		return err
+	}
+
+	form := url.Values{}
+	form.Add("apn_action", "delete")
+	form.Add("apn_mode", "manual")
+	form.Add("index", strconv.Itoa(index))
+
+	return m.setCmd("apn delete", "APN_PROC_EX", form)
+}
+
+func (m *zte8810ft) SetDefaultAPN(index int) error {
+	profiles, err := m.ListAPN()
+	if err != nil {
+		return err
+	}
+
+	for _, profile := range profiles {
+		if profile.Index != index {
+			continue
+		}
+
+		form := url.Values{}
+		form.Add("apn_action", "set_default")
+		form.Add("set_default_flag", "1")
+		form.Add("apn_mode", "manual")
+		form.Add("pdp_type", zteFromPDPType(profile.PDPType))
+		form.Add("index", strconv.Itoa(index))
+
+		return m.setCmd("apn set default", "APN_PROC_EX", form)
+	}
+
+	return ActionError{Action: "apn set default", Err: ErrAPNNotFound}
+}
@@ -0,0 +1,22 @@
+package drivers
+
+import "net/url"
+
+var _ ModemDevice = (*zte8810ft)(nil)
+
+func (m *zte8810ft) Ping() error {
+	raw := map[string]string{}
+	return m.getCmd("ping", []string{"modem_main_state"}, nil, &raw)
+}
+
+func (m *zte8810ft) Reboot() error {
+	return m.setCmd("reboot", "REBOOT_DEVICE", url.Values{})
+}
+
+func (m *zte8810ft) PowerOff() error {
+	return m.setCmd("power off", "TURN_OFF_DEVICE", url.Values{})
+}
+
+func (m *zte8810ft) FactoryReset() error {
+	return m.setCmd("factory reset", "RESTORE_FACTORY_SETTINGS", url.Values{})
+}
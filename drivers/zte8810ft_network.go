@@ -0,0 +1,245 @@
+package drivers
+
+import (
+	"fmt"
+	"math/big"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	_ ModemNetwork  = (*zte8810ft)(nil)
+	_ ModemCellInfo = (*zte8810ft)(nil)
+)
+
+// Operator scan may take a couple of minutes on a busy modem
+const (
+	zteScanTimeout      = 3 * time.Minute
+	zteScanPollInterval = 2 * time.Second
+)
+
+// "All bands" mask used by the web UI to unlock bands
+const zteAllBands = "0xFFFFFFFFFFFFFFFF"
+
+var zteNetworkModes = map[NetworkMode]string{
+	NetworkModeAuto:        "NETWORK_auto",
+	NetworkMode4G:          "Only_LTE",
+	NetworkMode3G:          "Only_WCDMA",
+	NetworkMode2G:          "Only_GSM",
+	NetworkMode4GPreferred: "LTE_preferred",
+	NetworkMode3GPreferred: "WCDMA_preferred",
+}
+
+type zteNetSelect struct {
+	NetSelect string `json:"net_select"`
+}
+
+type zteBandLock struct {
+	LTEBandLock string `json:"lte_band_lock"`
+}
+
+type zteNetScan struct {
+	Status   string `json:"m_netselect_status"`
+	Contents string `json:"m_netselect_contents"`
+}
+
+type zteCellInfo struct {
+	Operator    string `json:"network_provider"`
+	NetworkType string `json:"network_type"`
+	SignalBar   string `json:"signalbar"`
+	RSSI        string `json:"rssi"`
+	RSRP        string `json:"lte_rsrp"`
+	RSRQ        string `json:"lte_rsrq"`
+	SINR        string `json:"lte_snr"`
+	IPv4        string `json:"wan_ipaddr"`
+	IPv6        string `json:"ipv6_wan_ipaddr"`
+}
+
+func (m *zte8810ft) GetCellInfo() (*CellInfo, error) {
+	raw := new(zteCellInfo)
+	cmds := []string{"network_provider", "network_type", "signalbar", "rssi", "lte_rsrp", "lte_rsrq", "lte_snr", "wan_ipaddr", "ipv6_wan_ipaddr"}
+	if err := m.getCmd("cell info", cmds, nil, raw); err != nil {
+		return nil, err
+	}
+
+	// Values are empty when not registered, some firmwares add fractions
+	number := func(value string) int {
+		n, _ := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		return int(n)
+	}
+
+	return &CellInfo{
+		Operator:    raw.Operator,
+		NetworkType: raw.NetworkType,
+		SignalBars:  number(raw.SignalBar),
+		RSSI:        number(raw.RSSI),
+		RSRP:        number(raw.RSRP),
+		RSRQ:        number(raw.RSRQ),
+		SINR:        number(raw.SINR),
+		IPv4:        raw.IPv4,
+		IPv6:        raw.IPv6,
+	}, nil
+}
+
+func (m *zte8810ft) GetNetworkMode() (NetworkMode, error) {
+	raw := new(zteNetSelect)
+	if err := m.getCmd("network mode", []string{"net_select"}, nil, raw); err != nil {
+		return NetworkModeUnknown, err
+	}
+
+	for mode, value := range zteNetworkModes {
+		if value == raw.NetSelect {
+			return mode, nil
+		}
+	}
+
+	m.logger.With("net_select", raw.NetSelect).Debug("unknown network mode")
+	return NetworkModeUnknown, nil
+}
+
+func (m *zte8810ft) SetNetworkMode(mode NetworkMode) error {
+	value, ok := zteNetworkModes[mode]
+	if !ok {
+		return ActionError{Action: "network mode", Err: ErrUnsupportedMode}
+	}
+
+	form := url.Values{}
+	form.Add("BearerPreference", value)
+
+	return m.setCmd("network mode", "SET_BEARER_PREFERENCE", form)
+}
+
+func (m *zte8810ft) GetLTEBands() ([]int, error) {
+	raw := new(zteBandLock)
+	if err := m.getCmd("network bands", []string{"lte_band_lock"}, nil, raw); err != nil {
+		return nil, err
+	}
+
+	mask, ok := new(big.Int).SetString(strings.TrimPrefix(strings.ToLower(raw.LTEBandLock), "0x"), 16)
+	if !ok || strings.EqualFold(raw.LTEBandLock, zteAllBands) {
+		return []int{}, nil
+	}
+
+	// Bit n-1 of the mask stands for band n
+	bands := []int{}
+	for i := 0; i < mask.BitLen(); i++ {
+		if mask.Bit(i) == 1 {
+			bands = append(bands, i+1)
+		}
+	}
+
+	return bands, nil
+}
+
+func (m *zte8810ft) SetLTEBands(bands []int) error {
+	mask := zteAllBands
+	if len(bands) > 0 {
+		bits := new(big.Int)
+		for _, band := range bands {
+			if band < 1 || band > 64 {
+				return ActionError{Action: "network bands", Err: fmt.Errorf("invalid LTE band %d", band)}
+			}
+			bits.SetBit(bits, band-1, 1)
+		}
+		mask = "0x" + strings.ToUpper(bits.Text(16))
+	}
+
+	form := url.Values{}
+	form.Add("is_gw_band", "0")
+	form.Add("gw_band_mask", "0")
+	form.Add("is_lte_band", "1")
+	form.Add("lte_band_mask", mask)
+
+	return m.setCmd("network bands", "BAND_SELECT", form)
+}
+
+func (m *zte8810ft) ScanOperators() ([]Operator, error) {
+	if err := m.setCmd("network scan", "SCAN_NETWORK", url.Values{}); err != nil {
+		return nil, err
+	}
+
+	// Wait for the modem to finish the scan
+	deadline := time.Now().Add(zteScanTimeout)
+	raw := new(zteNetScan)
+	for {
+		time.Sleep(zteScanPollInterval)
+
+		if err := m.getCmd("network scan", []string{"m_netselect_status", "m_netselect_contents"}, nil, raw); err != nil {
+			return nil, err
+		}
+
+		if raw.Status == "manual_search_fail" {
+			return nil, ActionError{Action: "network scan", Err: ErrScanFailed}
+		}
+		if raw.Status != "manual_searching" {
+			break
+		}
+		if time.Now().After(deadline) {
+			return nil, ActionError{Action: "network scan", Err: ErrScanTimeout}
+		}
+	}
+
+	// Entries look like "state,name,plmn,rat" and are separated by ";"
+	operators := []Operator{}
+	for _, entry := range strings.Split(raw.Contents, ";") {
+		fields := strings.Split(entry, ",")
+		if len(fields) < 4 {
+			continue
+		}
+
+		op := Operator{Name: fields[1], PLMN: fields[2], RAT: zteToRAT(fields[3])}
+		if state, err := strconv.Atoi(fields[0]); err == nil && state <= int(OperatorForbidden) {
+			op.State = OperatorState(state)
+		}
+		operators = append(operators, op)
+	}
+	return operators, nil
+}
+
+func (m *zte8810ft) SelectOperator(op Operator) error {
+	form := url.Values{}
+	form.Add("NetworkNumber", op.PLMN)
+	form.Add("Rat", zteFromRAT(op.RAT))
+
+	return m.setCmd("network select", "SET_NETWORK", form)
+}
+
+func (m *zte8810ft) SelectOperatorAuto() error {
+	// Re-applying the bearer preference brings the modem back to automatic selection
+	raw := new(zteNetSelect)
+	if err := m.getCmd("network select", []string{"net_select"}, nil, raw); err != nil {
+		return err
+	}
+
+	form := url.Values{}
+	form.Add("BearerPreference", raw.NetSelect)
+
+	return m.setCmd("network select", "SET_BEARER_PREFERENCE", form)
+}
+
+// Converts 3GPP access technology numbers to modes
+func zteToRAT(raw string) NetworkMode {
+	switch raw {
+	case "0", "1", "3":
+		return NetworkMode2G
+	case "2", "4", "5", "6":
+		return NetworkMode3G
+	case "7":
+		return NetworkMode4G
+	default:
+		return NetworkModeUnknown
+	}
+}
+
+func zteFromRAT(rat NetworkMode) string {
+	switch rat {
+	case NetworkMode2G:
+		return "0"
+	case NetworkMode3G:
+		return "2"
+	default:
+		return "7"
+	}
+}
@@ -0,0 +1,44 @@
+package drivers
+
+import (
+	"fmt"
+	"net/url"
+)
+
+var _ ModemCellPolicy = (*zte8810ft)(nil)
+
+type zteConnPolicy struct {
+	DialMode string `json:"dial_mode"`
+	Roaming  string `json:"roam_setting_option"`
+}
+
+func (m *zte8810ft) GetConnPolicy() (*ConnPolicy, error) {
+	raw := new(zteConnPolicy)
+	if err := m.getCmd("conn config", []string{"dial_mode", "roam_setting_option"}, nil, raw); err != nil {
+		return nil, err
+	}
+
+	// Auto dial mode also takes care of reconnecting
+	autoDial := raw.DialMode == "auto_dial"
+	return &ConnPolicy{AutoDial: autoDial, Roaming: raw.Roaming == "on", Reconnect: autoDial}, nil
+}
+
+func (m *zte8810ft) SetConnPolicy(policy ConnPolicy) error {
+	if policy.Reconnect != policy.AutoDial {
+		return ActionError{Action: "conn config", Err: fmt.Errorf("%w: reconnecting is tied to auto dial", ErrUnsupportedPolicy)}
+	}
+
+	form := url.Values{}
+	if policy.AutoDial {
+		form.Add("ConnectionMode", "auto_dial")
+	} else {
+		form.Add("ConnectionMode", "manual_dial")
+	}
+	if policy.Roaming {
+		form.Add("roam_setting_option", "on")
+	} else {
+		form.Add("roam_setting_option", "off")
+	}
+
+	return m.setCmd("conn config", "SET_CONNECTION_MODE", form)
+}
@@ -0,0 +1,220 @@
+package drivers
+
+import (
+	"encoding/hex"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/warthog618/sms/encoding/ucs2"
+)
+
+var _ ModemSMSReport = (*zte8810ft)(nil)
+
+type (
+	zteSMSParameters struct {
+		SCA            string `json:"sms_para_sca"`
+		StatusReport   string `json:"sms_para_status_report"`
+		ValidityPeriod string `json:"sms_para_validity_period"`
+	}
+
+	zteReportFlag struct {
+		Flag string `json:"sts_received_flag"`
+	}
+
+	zteReport struct {
+		Number string
+		Time   time.Time
+		State  SMSDeliveryState
+	}
+)
+
+// Relative validity periods (TP-VP) used by SET_MESSAGE_CENTER
+var zteValidityPeriods = map[string]string{
+	"143": "twelve_hours",
+	"167": "one_day",
+	"173": "one_week",
+	"255": "largest",
+}
+
+// Turns on status reports for outgoing messages, keeping the other SMS settings.
+// It's a setting of the modem, so later messages ask for reports as well. It
+// isn't turned off again, since the modem sends in the background and could
+// pick the setting up before the message went out
+func (m *zte8810ft) enableStatusReport() error {
+	params := new(zteSMSParameters)
+	cmds := []string{"sms_para_sca", "sms_para_status_report", "sms_para_validity_period"}
+	if err := m.getCmd("sms report", cmds, nil, params); err != nil {
+		return err
+	}
+
+	if params.StatusReport == "1" {
+		return nil
+	}
+
+	validity, ok := zteValidityPeriods[params.ValidityPeriod]
+	if !ok {
+		validity = "largest"
+	}
+
+	form := url.Values{}
+	form.Add("save_time", validity)
+	form.Add("MessageCenter", params.SCA)
+	form.Add("status_save", "1")
+	form.Add("save_location", "native")
+
+	return m.setCmd("sms report", "SET_MESSAGE_CENTER", form)
+}
+
+// Returns the ID of the newest sent message to the phone with the text, empty
+// if it can't be found
+func (m *zte8810ft) findSentSMS(phone string, message string, since time.Time) string {
+	// Allow for the modem's clock being a bit off
+	it := m.IterSMS(SMSQuery{Folder: SMSFolderSent, Since: since.Add(-time.Minute)})
+	for it.Next() {
+		if sent := it.SMS(); SamePhone(sent.Sender, phone) && sent.Message == message {
+			return sent.ID
+		}
+	}
+
+	m.logger.With("err", it.Err(), "phone", phone).Debug("sent message not found")
+	return ""
+}
+
+// Reports aren't linked to messages, as the firmware doesn't keep the message
+// reference. Messages to a number are paired with its reports in order, which
+// only holds when no report was lost. The state is unknown when the numbers of
+// messages and reports don't add up, or when the message is older than the
+// reports
+func (m *zte8810ft) GetSMSDelivery(ref string) (*SMSDelivery, error) {
+	if ref == "" {
+		return &SMSDelivery{State: SMSUnknown}, nil
+	}
+
+	sent, err := m.ReadSMS(SMSQuery{Folder: SMSFolderSent})
+	if err != nil {
+		return nil, err
+	}
+	i := slices.IndexFunc(sent, func(s SMS) bool { return s.ID == ref })
+	if i < 0 {
+		return nil, ActionError{Action: "sms status", Err: ErrSMSNotFound}
+	}
+	message := sent[i]
+
+	reports, err := m.readStatusReports()
+	if err != nil {
+		return nil, err
+	}
+
+	// Oldest first on both sides
+	sent = slices.DeleteFunc(sent, func(s SMS) bool { return !SamePhone(s.Sender, message.Sender) })
+	slices.SortStableFunc(sent, func(a, b SMS) int {
+		if c := a.Time.Compare(b.Time); c != 0 {
+			return c
+		}
+		return CompareSMSID(a.ID, b.ID)
+	})
+	received := []zteReport{}
+	for j := len(reports) - 1; j >= 0; j-- {
+		if SamePhone(reports[j].Number, message.Sender) && !reports[j].Time.Before(sent[0].Time) {
+			received = append(received, reports[j])
+		}
+	}
+
+	// Nothing arrived since the message was sent
+	if len(received) == 0 || received[len(received)-1].Time.Before(message.Time) {
+		return &SMSDelivery{Ref: ref, State: SMSPending}, nil
+	}
+
+	// The first report belongs to the last message before it, older messages
+	// might not have asked for one. Messages after the last report can't have
+	// one yet
+	first, last := received[0].Time, received[len(received)-1].Time
+	start := 0
+	for start+1 < len(sent) && !sent[start+1].Time.After(first) {
+		start++
+	}
+	sent = slices.DeleteFunc(sent[start:], func(s SMS) bool { return s.Time.After(last) })
+	if len(sent) != len(received) {
+		return &SMSDelivery{Ref: ref, State: SMSUnknown}, nil
+	}
+
+	for j := range sent {
+		// A report older than its message means the order is off
+		if received[j].Time.Before(sent[j].Time) {
+			return &SMSDelivery{Ref: ref, State: SMSUnknown}, nil
+		}
+		if sent[j].ID == ref {
+			return &SMSDelivery{Ref: ref, State: received[j].State, Time: received[j].Time}, nil
+		}
+	}
+
+	return &SMSDelivery{Ref: ref, State: SMSUnknown}, nil
+}
+
+// Reads all status reports, newest first
+func (m *zte8810ft) readStatusReports() ([]zteReport, error) {
+	// Reports only need to be read again once a new one arrives
+	if m.reports != nil {
+		flag := new(zteReportFlag)
+		extra := url.Values{}
+		extra.Add("sts_received_flag_flag", "0")
+		if err := m.getCmd("sms status", []string{"sts_received_flag"}, extra, flag); err == nil && flag.Flag == "0" {
+			return m.reports, nil
+		}
+	}
+
+	extra := url.Values{}
+	extra.Add("page", "0")
+	extra.Add("data_per_page", strconv.Itoa(ztePageSize))
+	extra.Add("order_by", "order by id desc")
+
+	raw := new(zteSMSList)
+	if err := m.getCmd("sms status", []string{"sms_status_rpt_data"}, extra, raw); err != nil {
+		return nil, err
+	}
+
+	reports := []zteReport{}
+	for _, rawReport := range raw.Messages {
+		date, err := zteParseTime(rawReport.Date, m.zoneUnits)
+		if err != nil {
+			m.logger.With("id", rawReport.ID, "raw_date", rawReport.Date).Debug("skipping status report with malformed date")
+			continue
+		}
+
+		reports = append(reports, zteReport{Number: rawReport.Source, Time: date, State: zteParseReport(rawReport.Content)})
+	}
+
+	m.reports = reports
+	return reports, nil
+}
+
+// Report content is either a TP-Status code or a short text
+func zteParseReport(content string) SMSDeliveryState {
+	content = strings.TrimSpace(content)
+
+	if status, err := strconv.ParseInt(content, 0, 16); err == nil {
+		return DeliveryStateFromTPStatus(int(status))
+	}
+	if raw, err := hex.DecodeString(content); err == nil && len(raw) == 1 {
+		return DeliveryStateFromTPStatus(int(raw[0]))
+	}
+	if raw, err := hex.DecodeString(content); err == nil {
+		if runes, err := ucs2.Decode(raw); err == nil {
+			content = string(runes)
+		}
+	}
+
+	switch content = strings.ToLower(content); {
+	case strings.Contains(content, "expire"):
+		return SMSExpired
+	case strings.Contains(content, "fail"), strings.Contains(content, "reject"):
+		return SMSFailed
+	case strings.Contains(content, "success"), strings.Contains(content, "deliver"):
+		return SMSDelivered
+	default:
+		return SMSPending
+	}
+}
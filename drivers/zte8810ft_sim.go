@@ -0,0 +1,87 @@
+package drivers
+
+import (
+	"net/url"
+	"strconv"
+)
+
+var _ ModemSIM = (*zte8810ft)(nil)
+
+type zteSIMStatus struct {
+	MainState string `json:"modem_main_state"`
+	PINStatus string `json:"pin_status"`
+	PINNumber string `json:"pinnumber"`
+	PUKNumber string `json:"puknumber"`
+	ICCID     string `json:"iccid"`
+	SIMICCID  string `json:"sim_iccid"`
+}
+
+func (m *zte8810ft) GetSIMStatus() (*SIMStatus, error) {
+	raw := new(zteSIMStatus)
+	cmds := []string{"modem_main_state", "pin_status", "pinnumber", "puknumber", "iccid", "sim_iccid"}
+	if err := m.getCmd("sim status", cmds, nil, raw); err != nil {
+		return nil, err
+	}
+
+	status := &SIMStatus{PINLock: raw.PINStatus == "1", PINAttempts: -1, PUKAttempts: -1, ICCID: raw.ICCID}
+	if status.ICCID == "" {
+		status.ICCID = raw.SIMICCID
+	}
+
+	if n, err := strconv.Atoi(raw.PINNumber); err == nil {
+		status.PINAttempts = n
+	}
+	if n, err := strconv.Atoi(raw.PUKNumber); err == nil {
+		status.PUKAttempts = n
+	}
+
+	switch raw.MainState {
+	case "modem_init_complete":
+		status.State = SIMReady
+	case "modem_waitpin":
+		status.State = SIMPINRequired
+	case "modem_waitpuk":
+		status.State = SIMPUKRequired
+	case "modem_sim_undetected", "modem_undetected", "modem_sim_destroy":
+		status.State = SIMAbsent
+	default:
+		m.logger.With("modem_main_state", raw.MainState).Debug("unknown SIM state")
+		status.State = SIMUnknown
+	}
+
+	return status, nil
+}
+
+func (m *zte8810ft) EnterPIN(pin string) error {
+	form := url.Values{}
+	form.Add("PinNumber", pin)
+
+	return m.setCmd("sim enter pin", "ENTER_PIN", form)
+}
+
+func (m *zte8810ft) EnterPUK(puk string, newPin string) error {
+	form := url.Values{}
+	form.Add("PUKNumber", puk)
+	form.Add("PinNumber", newPin)
+
+	return m.setCmd("sim enter puk", "ENTER_PUK", form)
+}
+
+func (m *zte8810ft) SetPINLock(enabled bool, pin string) error {
+	form := url.Values{}
+	form.Add("OldPinNumber", pin)
+
+	if enabled {
+		return m.setCmd("sim enable pin", "ENABLE_PIN", form)
+	}
+	return m.setCmd("sim disable pin", "DISABLE_PIN", form)
+}
+
+func (m *zte8810ft) ChangePIN(oldPin string, newPin string) error {
+	// Changing the PIN is only possible with the lock enabled
+	form := url.Values{}
+	form.Add("OldPinNumber", oldPin)
+	form.Add("NewPinNumber", newPin)
+
+	return m.setCmd("sim change pin", "ENABLE_PIN", form)
+}
@@ -0,0 +1,441 @@
+package drivers
+
+import (
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/warthog618/sms/encoding/gsm7"
+	"github.com/warthog618/sms/encoding/ucs2"
+)
+
+var (
+	_ ModemSMSNotify   = (*zte8810ft)(nil)
+	_ ModemSMSStore    = (*zte8810ft)(nil)
+	_ ModemSMSCapacity = (*zte8810ft)(nil)
+	_ ModemSMSStorage  = (*zte8810ft)(nil)
+)
+
+type (
+	zteRawSMS struct {
+		ID           string `json:"id"`
+		Source       string `json:"number"`
+		Content      string `json:"content"`
+		Tag          string `json:"tag"`
+		Date         string `json:"date"`
+		DraftGroupID string `json:"draft_group_id"`
+	}
+
+	zteSMSList struct {
+		Messages []zteRawSMS `json:"messages"`
+	}
+
+	// Message counts in device memory
+	zteSMSCapacity struct {
+		Used      string `json:"sms_nvused_total"`
+		Inbox     string `json:"sms_nv_rev_total"`
+		Sent      string `json:"sms_nv_send_total"`
+		Drafts    string `json:"sms_nv_draftbox_total"`
+		Total     string `json:"sms_nv_total"`
+		SIMInbox  string `json:"sms_sim_rev_total"`
+		SIMSent   string `json:"sms_sim_send_total"`
+		SIMDrafts string `json:"sms_sim_draftbox_total"`
+		SIMTotal  string `json:"sms_sim_total"`
+	}
+
+	// Walks sms_data_total page by page
+	zteSMSIterator struct {
+		m       *zte8810ft
+		query   SMSQuery
+		tags    string
+		perPage int
+		page    int
+		total   int // Messages expected in the folder, -1 if unknown
+		fetched int
+		matched int
+		buf     []zteRawSMS
+		current SMS
+		err     error
+		done    bool
+	}
+)
+
+// Messages per sms_data_total request
+const ztePageSize = 100
+
+// Values of the "tags" query parameter
+const (
+	zteTagsUnread = "1"
+	zteTagsSent   = "2"
+	zteTagsAll    = "10"
+	zteTagsDrafts = "11"
+	zteTagsInbox  = "12" // Read and unread
+)
+
+// Message tags: 0 - read, 1 - unread, 2 - sent, 3 - failed to send, 4 - draft
+func zteTagToFolder(tag string) SMSFolder {
+	switch tag {
+	case "2", "3":
+		return SMSFolderSent
+	case "4":
+		return SMSFolderDrafts
+	default:
+		return SMSFolderInbox
+	}
+}
+
+func (m *zte8810ft) ReadAllSMS() ([]SMS, error) {
+	return m.ReadSMS(SMSQuery{Folder: SMSFolderInbox})
+}
+
+func (m *zte8810ft) ReadSMS(q SMSQuery) ([]SMS, error) {
+	return CollectSMS(m.IterSMS(q))
+}
+
+func (m *zte8810ft) IterSMS(q SMSQuery) SMSIterator {
+	it := &zteSMSIterator{m: m, query: q, tags: zteTagsInbox, perPage: ztePageSize, total: -1}
+
+	// Folder and unread state can be filtered by the modem
+	switch {
+	case q.UnreadOnly:
+		it.tags = zteTagsUnread
+	case q.Folder == SMSFolderAll:
+		it.tags = zteTagsAll
+	case q.Folder == SMSFolderSent:
+		it.tags = zteTagsSent
+	case q.Folder == SMSFolderDrafts:
+		it.tags = zteTagsDrafts
+	}
+
+	// Let the modem cut the list if nothing else has to be filtered out
+	if q.Limit > 0 && q.Limit < ztePageSize && q.Sender == "" && q.Since.IsZero() && q.Until.IsZero() {
+		it.perPage = q.Limit
+	}
+
+	// Folder sizes tell when to stop, unread messages aren't counted separately
+	capacity := new(zteSMSCapacity)
+	if err := m.getCmd("sms read", []string{"sms_capacity_info"}, nil, capacity); err != nil {
+		m.logger.With("err", err).Debug("failed to get sms capacity, reading until an empty page")
+		return it
+	}
+
+	var totals []string
+	switch {
+	case q.Storage == SMSStorageSIM && it.tags == zteTagsAll:
+		totals = []string{capacity.SIMInbox, capacity.SIMSent, capacity.SIMDrafts}
+	case q.Storage == SMSStorageSIM && it.tags == zteTagsInbox:
+		totals = []string{capacity.SIMInbox}
+	case q.Storage == SMSStorageSIM && it.tags == zteTagsSent:
+		totals = []string{capacity.SIMSent}
+	case q.Storage == SMSStorageSIM && it.tags == zteTagsDrafts:
+		totals = []string{capacity.SIMDrafts}
+	case it.tags == zteTagsAll:
+		totals = []string{capacity.Used}
+	case it.tags == zteTagsInbox:
+		totals = []string{capacity.Inbox}
+	case it.tags == zteTagsSent:
+		totals = []string{capacity.Sent}
+	case it.tags == zteTagsDrafts:
+		totals = []string{capacity.Drafts}
+	default:
+		return it
+	}
+
+	total := 0
+	for _, field := range totals {
+		n, err := strconv.Atoi(field)
+		if err != nil {
+			return it
+		}
+		total += n
+	}
+	it.total = total
+
+	return it
+}
+
+func (it *zteSMSIterator) Next() bool {
+	for !it.done && it.err == nil {
+		if it.query.Limit > 0 && it.matched >= it.query.Limit {
+			it.done = true
+			break
+		}
+
+		if len(it.buf) == 0 && !it.fetch() {
+			break
+		}
+
+		raw := it.buf[0]
+		it.buf = it.buf[1:]
+
+		message, err := it.m.decodeSMS(&raw)
+		if err != nil {
+			it.err = err
+			break
+		}
+
+		if it.query.Match(message) {
+			it.matched++
+			it.current = *message
+			return true
+		}
+	}
+
+	return false
+}
+
+// Loads the next page, returns false once there are no more messages
+func (it *zteSMSIterator) fetch() bool {
+	if it.total >= 0 && it.fetched >= it.total {
+		it.done = true
+		return false
+	}
+
+	// http://10.96.170.1/goform/goform_get_cmd_process?cmd=sms_data_total&page=0&data_per_page=100&mem_store=1&tags=12&order_by=order+by+id+desc&_=1724578532798
+	extra := url.Values{}
+	extra.Add("page", strconv.Itoa(it.page))
+	extra.Add("data_per_page", strconv.Itoa(it.perPage))
+	if it.query.Storage == SMSStorageSIM {
+		extra.Add("mem_store", "0")
+	} else {
+		extra.Add("mem_store", "1")
+	}
+	extra.Add("tags", it.tags)
+	extra.Add("order_by", "order by id desc")
+
+	rawSMS := new(zteSMSList)
+	if err := it.m.getCmd("sms read", []string{"sms_data_total"}, extra, rawSMS); err != nil {
+		it.err = err
+		return false
+	}
+
+	it.page++
+	it.fetched += len(rawSMS.Messages)
+	it.buf = rawSMS.Messages
+
+	// A short page is the last one
+	if len(rawSMS.Messages) < it.perPage {
+		it.done = len(rawSMS.Messages) == 0
+		it.total = it.fetched
+	}
+
+	return len(it.buf) > 0
+}
+
+func (it *zteSMSIterator) SMS() *SMS {
+	return &it.current
+}
+
+func (it *zteSMSIterator) Err() error {
+	return it.err
+}
+
+// Dates look like "24,01,15,10,30,00,+8". The zone is counted in hours or
+// quarter hours depending on the firmware, see modem.time_zone_unit
+func zteParseTime(raw string, zoneUnits float64) (time.Time, error) {
+	fields := strings.Split(raw, ",")
+	if len(fields) != 7 {
+		return time.Time{}, fmt.Errorf("unexpected date format %q", raw)
+	}
+
+	t, err := time.Parse("06,01,02,15,04,05", strings.Join(fields[:6], ","))
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	zone, err := strconv.ParseFloat(strings.TrimSpace(fields[6]), 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("unexpected time zone %q", fields[6])
+	}
+
+	loc := time.FixedZone("", int(zone/zoneUnits*3600))
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), 0, loc), nil
+}
+
+// Formats time the way the web UI sends it, with the zone in the firmware's unit
+func zteFormatTime(t time.Time, zoneUnits float64) string {
+	_, tz := t.Zone()
+	zone := strconv.FormatFloat(float64(tz)/3600*zoneUnits, 'f', -1, 64)
+	if tz >= 0 {
+		zone = "+" + zone
+	}
+	return t.Format("06;01;02;15;04;05;") + zone
+}
+
+// Returns the message body and encode_type expected by the modem
+func zteEncodeMessage(message string, encoding SMSEncoding) (body string, encodeType string, err error) {
+	switch encoding {
+	case SMSEncodingUCS2:
+		// UCS-2 code units as hex
+		return strings.ToUpper(hex.EncodeToString(ucs2.Encode([]rune(message)))), "UNICODE", nil
+	default:
+		// Encode message into GSM-7 as byte array, extension characters are escaped
+		encodedMsg, err := gsm7.Encode([]byte(message))
+		if err != nil {
+			return "", "", fmt.Errorf("%w: %w", ErrNotGSM7, err)
+		}
+
+		// Format into proprietary two byte format: 1122 (0074)
+		var formatted strings.Builder
+		for _, septet := range encodedMsg {
+			fmt.Fprintf(&formatted, "%04X", septet)
+		}
+		return formatted.String(), "GSM7_default", nil
+	}
+}
+
+// The modem joins concatenated messages itself, so Part is never set
+func (m *zte8810ft) decodeSMS(raw *zteRawSMS) (*SMS, error) {
+	message := &SMS{
+		ID:     raw.ID,
+		Folder: zteTagToFolder(raw.Tag),
+		Sender: raw.Source,
+		Read:   raw.Tag != "1",
+	}
+
+	// Extract datetime
+	date, err := zteParseTime(raw.Date, m.zoneUnits)
+	if err != nil {
+		m.logger.With("id", raw.ID, "raw_date", raw.Date, "err", err).Debug("failed to parse datetime")
+		return nil, ActionError{Action: "sms read", Err: fmt.Errorf("failed to decode message datetime")}
+	}
+	message.Time = date
+
+	// Extract contents
+	rawBytes, err := hex.DecodeString(raw.Content)
+	if err != nil {
+		m.logger.With("id", raw.ID, "raw_content", raw.Content).Debug("failed to parse content")
+		return nil, ActionError{Action: "sms read", Err: fmt.Errorf("failed to parse message content")}
+	}
+
+	runes, err := ucs2.Decode(rawBytes)
+	if err != nil {
+		m.logger.With("id", raw.ID, "raw_content", raw.Content).Debug("failed to decode content")
+		return nil, ActionError{Action: "sms read", Err: fmt.Errorf("failed to decode message content")}
+	}
+
+	message.Message = string(runes)
+	return message, nil
+}
+
+// Message IDs are sent as "1;2;3;"
+func zteJoinIDs(ids []string) string {
+	return strings.Join(ids, ";") + ";"
+}
+
+func (m *zte8810ft) DeleteSMS(ids ...string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	form := url.Values{}
+	form.Add("msg_id", zteJoinIDs(ids))
+	form.Add("notCallback", "true")
+
+	return m.setCmd("sms delete", "DELETE_SMS", form)
+}
+
+func (m *zte8810ft) DeleteAllSMS() error {
+	messages, err := m.ReadSMS(SMSQuery{Folder: SMSFolderAll})
+	if err != nil {
+		return err
+	}
+
+	ids := make([]string, len(messages))
+	for i := range messages {
+		ids[i] = messages[i].ID
+	}
+
+	return m.DeleteSMS(ids...)
+}
+
+func (m *zte8810ft) MarkSMSRead(ids ...string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	form := url.Values{}
+	form.Add("msg_id", zteJoinIDs(ids))
+	form.Add("tag", "0")
+
+	return m.setCmd("sms mark read", "SET_MSG_READ", form)
+}
+
+type zteNewSMS struct {
+	ReceivedFlag string `json:"sms_received_flag"`
+	UnreadNum    string `json:"sms_unread_num"`
+}
+
+func (m *zte8810ft) GetSMSNotify() (*SMSNotify, error) {
+	raw := new(zteNewSMS)
+	extra := url.Values{}
+	extra.Add("sms_received_flag_flag", "0")
+	if err := m.getCmd("sms check", []string{"sms_received_flag", "sms_unread_num"}, extra, raw); err != nil {
+		return nil, err
+	}
+
+	// Empty without a SIM
+	unread, _ := strconv.Atoi(raw.UnreadNum)
+	return &SMSNotify{Received: raw.ReceivedFlag == "1", Unread: unread}, nil
+}
+
+// The modem keeps stored messages as drafts, whatever folder they came from
+func (m *zte8810ft) StoreSMS(message SMS) error {
+	info, err := CountSMS(message.Message, SMSEncodingAuto)
+	if err != nil {
+		return ActionError{Action: "sms store", Err: err}
+	}
+	if info.Segments > zteMaxSegments {
+		return ActionError{Action: "sms store", Err: fmt.Errorf("%w: %d parts, at most %d are supported", ErrSMSTooLong, info.Segments, zteMaxSegments)}
+	}
+
+	body, encodeType, err := zteEncodeMessage(message.Message, info.Encoding)
+	if err != nil {
+		return ActionError{Action: "sms store", Err: err}
+	}
+
+	t := message.Time
+	if t.IsZero() {
+		t = time.Now().Truncate(time.Second)
+	}
+
+	return m.setCmd("sms store", "SAVE_SMS", url.Values{
+		"notCallback":    {"true"},
+		"SMSMessage":     {body},
+		"SMSNumber":      {message.Sender + ";"},
+		"Index":          {"-1"},
+		"encode_type":    {encodeType},
+		"sms_time":       {zteFormatTime(t, m.zoneUnits)},
+		"draft_group_id": {""},
+	})
+}
+
+func (m *zte8810ft) GetSMSStorages() []SMSStorage {
+	return []SMSStorage{SMSStorageDevice, SMSStorageSIM}
+}
+
+func (m *zte8810ft) GetSMSCapacity() (*SMSCapacity, error) {
+	raw := new(zteSMSCapacity)
+	if err := m.getCmd("sms capacity", []string{"sms_capacity_info"}, nil, raw); err != nil {
+		return nil, err
+	}
+
+	// Fields are left empty when there is no SIM
+	count := func(fields ...string) (n int) {
+		for _, field := range fields {
+			v, _ := strconv.Atoi(field)
+			n += v
+		}
+		return
+	}
+
+	return &SMSCapacity{
+		SIMUsed:     count(raw.SIMInbox, raw.SIMSent, raw.SIMDrafts),
+		SIMTotal:    count(raw.SIMTotal),
+		DeviceUsed:  count(raw.Used),
+		DeviceTotal: count(raw.Total),
+	}, nil
+}
@@ -0,0 +1,115 @@
+package drivers
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var _ ModemTrafficReset = (*zte8810ft)(nil)
+
+const zteMB = 1024 * 1024
+
+type zteTrafficStats struct {
+	RealtimeRX    string `json:"realtime_rx_bytes"`
+	RealtimeTX    string `json:"realtime_tx_bytes"`
+	RealtimeTime  string `json:"realtime_time"`
+	RealtimeRXBps string `json:"realtime_rx_thrpt"`
+	RealtimeTXBps string `json:"realtime_tx_thrpt"`
+	MonthlyRX     string `json:"monthly_rx_bytes"`
+	MonthlyTX     string `json:"monthly_tx_bytes"`
+	MonthlyTime   string `json:"monthly_time"`
+}
+
+type zteDataLimit struct {
+	Switch       string `json:"data_volume_limit_switch"`
+	Unit         string `json:"data_volume_limit_unit"`
+	Size         string `json:"data_volume_limit_size"`
+	AlertPercent string `json:"data_volume_alert_percent"`
+}
+
+// Counters are reported as decimal strings, empty when unknown
+func zteParseUint(raw string) uint64 {
+	n, _ := strconv.ParseUint(raw, 10, 64)
+	return n
+}
+
+func (m *zte8810ft) GetTrafficStats() (*TrafficStats, error) {
+	raw := new(zteTrafficStats)
+	cmds := []string{"realtime_rx_bytes", "realtime_tx_bytes", "realtime_time", "realtime_rx_thrpt", "realtime_tx_thrpt",
+		"monthly_rx_bytes", "monthly_tx_bytes", "monthly_time"}
+	if err := m.getCmd("usage", cmds, nil, raw); err != nil {
+		return nil, err
+	}
+
+	return &TrafficStats{
+		SessionRX:       zteParseUint(raw.RealtimeRX),
+		SessionTX:       zteParseUint(raw.RealtimeTX),
+		SessionDuration: time.Duration(zteParseUint(raw.RealtimeTime)) * time.Second,
+		MonthlyRX:       zteParseUint(raw.MonthlyRX),
+		MonthlyTX:       zteParseUint(raw.MonthlyTX),
+		MonthlyDuration: time.Duration(zteParseUint(raw.MonthlyTime)) * time.Second,
+		RXRate:          zteParseUint(raw.RealtimeRXBps),
+		TXRate:          zteParseUint(raw.RealtimeTXBps),
+	}, nil
+}
+
+func (m *zte8810ft) GetDataLimit() (*DataLimit, error) {
+	raw := new(zteDataLimit)
+	cmds := []string{"data_volume_limit_switch", "data_volume_limit_unit", "data_volume_limit_size", "data_volume_alert_percent"}
+	if err := m.getCmd("usage limit", cmds, nil, raw); err != nil {
+		return nil, err
+	}
+
+	limit := &DataLimit{Enabled: raw.Switch == "1", Type: DataLimitVolume}
+	limit.AlertPercent, _ = strconv.Atoi(raw.AlertPercent)
+
+	// Size is "<value>_<MB multiplier>" for data and hours for time limits
+	if raw.Unit == "time" {
+		limit.Type = DataLimitTime
+		limit.Time = time.Duration(zteParseUint(raw.Size)) * time.Hour
+	} else {
+		value, unit, _ := strings.Cut(raw.Size, "_")
+		limit.Volume = zteParseUint(value) * zteParseUint(unit) * zteMB
+	}
+
+	return limit, nil
+}
+
+func (m *zte8810ft) SetDataLimit(limit DataLimit) error {
+	form := url.Values{}
+	if !limit.Enabled {
+		form.Add("data_volume_limit_switch", "0")
+		return m.setCmd("usage limit", "DATA_LIMIT_SETTING", form)
+	}
+
+	form.Add("data_volume_limit_switch", "1")
+	form.Add("data_volume_alert_percent", strconv.Itoa(limit.AlertPercent))
+
+	switch limit.Type {
+	case DataLimitTime:
+		form.Add("data_volume_limit_unit", "time")
+		form.Add("data_volume_limit_size", strconv.FormatInt(int64(limit.Time/time.Hour), 10))
+	default:
+		// The modem only understands whole MB and GB
+		form.Add("data_volume_limit_unit", "data")
+		if mb := limit.Volume / zteMB; mb%1024 == 0 {
+			form.Add("data_volume_limit_size", fmt.Sprintf("%d_1024", mb/1024))
+		} else {
+			form.Add("data_volume_limit_size", fmt.Sprintf("%d_1", mb))
+		}
+	}
+
+	return m.setCmd("usage limit", "DATA_LIMIT_SETTING", form)
+}
+
+func (m *zte8810ft) ResetTrafficStats() error {
+	form := url.Values{}
+	form.Add("calibration_way", "data")
+	form.Add("data", "0")
+	form.Add("time", "0")
+
+	return m.setCmd("usage reset", "FLOW_CALIBRATION_MANUAL", form)
+}
@@ -0,0 +1,183 @@
+package drivers
+
+import (
+	"encoding/base64"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+var _ ModemWiFi = (*zte8810ft)(nil)
+
+var zteSecurityModes = map[string]string{
+	WiFiSecurityOpen:     "OPEN",
+	WiFiSecurityWPA2:     "WPA2PSK",
+	WiFiSecurityWPAWPA2:  "WPAPSKWPA2PSK",
+	WiFiSecurityWPA3:     "WPA3Personal",
+	WiFiSecurityWPA2WPA3: "WPA2WPA3",
+}
+
+type zteWiFiSettings struct {
+	SSID       string `json:"SSID1"`
+	Passphrase string `json:"WPAPSK1_encode"` // Base64
+	AuthMode   string `json:"AuthMode"`
+	Channel    string `json:"Channel"`
+	HideSSID   string `json:"HideSSID"`
+	RadioOff   string `json:"RadioOff"` // "1" means the radio is on
+	MaxAccess  string `json:"MAX_Access_num"`
+}
+
+type zteStationList struct {
+	Stations []struct {
+		MAC      string `json:"mac_addr"`
+		IP       string `json:"ip_addr"`
+		Hostname string `json:"hostname"`
+	} `json:"station_list"`
+}
+
+type zteMACFilter struct {
+	BlackList     string `json:"wifi_mac_black_list"`
+	HostBlackList string `json:"wifi_hostname_black_list"`
+}
+
+func (m *zte8810ft) GetWiFiSettings() (*WiFiSettings, error) {
+	raw := new(zteWiFiSettings)
+	cmds := []string{"SSID1", "WPAPSK1_encode", "AuthMode", "Channel", "HideSSID", "RadioOff", "MAX_Access_num"}
+	if err := m.getCmd("wifi status", cmds, nil, raw); err != nil {
+		return nil, err
+	}
+
+	settings := &WiFiSettings{
+		Enabled:   raw.RadioOff == "1",
+		SSID:      raw.SSID,
+		Broadcast: raw.HideSSID != "1",
+		Security:  WiFiSecurityWPA2,
+	}
+
+	for security, value := range zteSecurityModes {
+		if value == raw.AuthMode {
+			settings.Security = security
+		}
+	}
+
+	if passphrase, err := base64.StdEncoding.DecodeString(raw.Passphrase); err == nil {
+		settings.Passphrase = string(passphrase)
+	}
+
+	// Channel is "0" or "auto" for automatic selection
+	settings.Channel, _ = strconv.Atoi(raw.Channel)
+
+	return settings, nil
+}
+
+func (m *zte8810ft) SetWiFiSettings(settings WiFiSettings) error {
+	current, err := m.GetWiFiSettings()
+	if err != nil {
+		return err
+	}
+	raw := new(zteWiFiSettings)
+	if err := m.getCmd("wifi set", []string{"MAX_Access_num"}, nil, raw); err != nil {
+		return err
+	}
+
+	// Radio has to be on to apply the SSID settings, so they go in before turning it off
+	ssidChanged := settings.SSID != current.SSID || settings.Passphrase != current.Passphrase ||
+		settings.Security != current.Security || settings.Broadcast != current.Broadcast
+	switch {
+	case settings.Enabled:
+		if err := m.setWiFiRadio(settings); err != nil {
+			return err
+		}
+		return m.setWiFiSSID(settings, raw.MaxAccess)
+	case !current.Enabled && ssidChanged:
+		return ActionError{Action: "wifi set", Err: ErrWiFiOff}
+	case ssidChanged:
+		if err := m.setWiFiSSID(settings, raw.MaxAccess); err != nil {
+			return err
+		}
+	}
+
+	return m.setWiFiRadio(settings)
+}
+
+// Turns the radio on or off and sets the channel
+func (m *zte8810ft) setWiFiRadio(settings WiFiSettings) error {
+	channel := "auto"
+	if settings.Channel > 0 {
+		channel = strconv.Itoa(settings.Channel)
+	}
+	enabled := "0"
+	if settings.Enabled {
+		enabled = "1"
+	}
+
+	form := url.Values{}
+	form.Add("wifiEnabled", enabled)
+	form.Add("selectedChannel", channel)
+	return m.setCmd("wifi set", "SET_WIFI_INFO", form)
+}
+
+func (m *zte8810ft) setWiFiSSID(settings WiFiSettings, maxAccess string) error {
+	security, ok := zteSecurityModes[settings.Security]
+	if !ok {
+		security = zteSecurityModes[WiFiSecurityWPA2]
+	}
+	hidden := "0"
+	if !settings.Broadcast {
+		hidden = "1"
+	}
+
+	form := url.Values{}
+	form.Add("ssid", settings.SSID)
+	form.Add("broadcastSsidEnabled", hidden)
+	form.Add("MAX_Access_num", maxAccess)
+	form.Add("security_mode", security)
+	form.Add("cipher", "1")
+	form.Add("NoForwarding", "0")
+	form.Add("security_shared_mode", "1")
+	if settings.Security != WiFiSecurityOpen {
+		form.Add("passphrase", base64.StdEncoding.EncodeToString([]byte(settings.Passphrase)))
+	}
+
+	return m.setCmd("wifi set", "SET_WIFI_SSID1_SETTINGS", form)
+}
+
+func (m *zte8810ft) ListWiFiClients() ([]WiFiClient, error) {
+	raw := new(zteStationList)
+	if err := m.getCmd("wifi clients", []string{"station_list"}, nil, raw); err != nil {
+		return nil, err
+	}
+
+	clients := make([]WiFiClient, len(raw.Stations))
+	for i, station := range raw.Stations {
+		clients[i] = WiFiClient{MAC: strings.ToUpper(station.MAC), IP: station.IP, Hostname: station.Hostname}
+	}
+
+	return clients, nil
+}
+
+func (m *zte8810ft) GetMACBlockList() ([]string, error) {
+	raw := new(zteMACFilter)
+	if err := m.getCmd("wifi block list", []string{"wifi_mac_black_list", "wifi_hostname_black_list"}, nil, raw); err != nil {
+		return nil, err
+	}
+
+	macs := []string{}
+	for _, mac := range strings.Split(raw.BlackList, ";") {
+		if mac != "" {
+			macs = append(macs, strings.ToUpper(mac))
+		}
+	}
+
+	return macs, nil
+}
+
+func (m *zte8810ft) SetMACBlockList(macs []string) error {
+	// Hostnames are matched to the MACs by position, leave them empty
+	form := url.Values{}
+	form.Add("ACL_mode", "2")
+	form.Add("wifi_mac_black_list", strings.Join(macs, ";"))
+	form.Add("wifi_hostname_black_list", strings.Repeat(";", max(len(macs)-1, 0)))
+
+	return m.setCmd("wifi block", "WIFI_MAC_FILTER", form)
+}
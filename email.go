@@ -0,0 +1,699 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/brokenCursor/usb-modem-cli/config"
+	"github.com/brokenCursor/usb-modem-cli/drivers"
+	"github.com/brokenCursor/usb-modem-cli/state"
+	"github.com/emersion/go-imap"
+	imapclient "github.com/emersion/go-imap/client"
+	"github.com/emersion/go-sasl"
+	"github.com/emersion/go-smtp"
+	"github.com/i582/cfmt/cmd/cfmt"
+	"github.com/spf13/viper"
+)
+
+const emailSpoolFile = "email-spool.json"
+
+type (
+	// Mail server the bridge connects to
+	emailServer struct {
+		Host     string // host:port
+		TLS      string // tls, starttls or none
+		Username string // Login is skipped without one
+		Password string
+	}
+
+	// Incoming messages wait in the spool until the SMTP server accepts them
+	emailSpool struct {
+		LastID     int             `json:"last_id"`
+		Deliveries []emailDelivery `json:"deliveries"`
+	}
+
+	emailDelivery struct {
+		ID          string      `json:"id"`
+		SMS         drivers.SMS `json:"sms"`
+		Attempts    int         `json:"attempts"`
+		NextAttempt time.Time   `json:"next_attempt"`
+		LastError   string      `json:"last_error,omitempty"`
+	}
+
+	// Forwards incoming SMS as emails over SMTP, and queues SMS for emails sent
+	// to <number>@<domain>, polled over IMAP or received by the built-in listener
+	emailBridge struct {
+		modem   drivers.BaseModem
+		watcher *smsWatcher // Nil when incoming SMS aren't forwarded
+		smtp    emailServer
+		imap    emailServer
+		mailbox string
+		listen  string
+		from    string
+		to      []string
+		domain  string
+		allow   []string
+		secret  string // Has to be in the subject, since From can be forged
+		policy  retryPolicy
+		timeout time.Duration
+	}
+)
+
+var ErrEmailNothingToDo = errors.New("email bridge needs email.smtp.host, email.imap.host or email.listen")
+var ErrEmailAllow = errors.New("email.allow must list the addresses allowed to send SMS")
+var ErrEmailNoSecret = errors.New("email.secret must be set to poll IMAP, anyone can put an allowed address into From")
+var ErrEmailSecret = errors.New("subject doesn't contain email.secret")
+var ErrEmailSender = errors.New("sender is not allowed to send SMS")
+var ErrEmailRecipient = errors.New("no recipient is an SMS address")
+
+func newEmailBridge(modem drivers.BaseModem, sms drivers.ModemSMS) (*emailBridge, error) {
+	c := config.Sub("email")
+
+	b := &emailBridge{
+		modem:   modem,
+		smtp:    emailServerFromConfig(c, "smtp"),
+		imap:    emailServerFromConfig(c, "imap"),
+		mailbox: c.GetString("imap.mailbox"),
+		listen:  c.GetString("listen"),
+		from:    c.GetString("smtp.from"),
+		to:      c.GetStringSlice("smtp.to"),
+		domain:  strings.ToLower(c.GetString("domain")),
+		allow:   c.GetStringSlice("allow"),
+		secret:  c.GetString("secret"),
+		policy:  retryPolicyFromConfig("email"),
+		timeout: c.GetDuration("timeout"),
+	}
+
+	if b.smtp.Host == "" && b.imap.Host == "" && b.listen == "" {
+		return nil, ErrEmailNothingToDo
+	}
+	if err := validate.Var(b.domain, "required,fqdn|hostname"); err != nil {
+		return nil, fmt.Errorf("invalid email.domain %q", b.domain)
+	}
+	for _, server := range []struct {
+		name   string
+		server emailServer
+	}{{"smtp", b.smtp}, {"imap", b.imap}} {
+		if server.server.Host != "" && !slices.Contains([]string{"tls", "starttls", "none"}, server.server.TLS) {
+			return nil, fmt.Errorf("invalid email.%s.tls %q, use tls, starttls or none", server.name, server.server.TLS)
+		}
+	}
+
+	if b.smtp.Host != "" {
+		if err := validate.Var(b.from, "required,email"); err != nil {
+			return nil, fmt.Errorf("invalid email.smtp.from %q", b.from)
+		}
+		if err := validate.Var(b.to, "required,min=1,dive,email"); err != nil {
+			return nil, fmt.Errorf("invalid email.smtp.to %q", b.to)
+		}
+
+		var err error
+		if b.watcher, err = newSMSWatcher(sms, "email", config.Sub("modem").GetString("host")); err != nil {
+			return nil, err
+		}
+	}
+
+	if (b.imap.Host != "" || b.listen != "") && len(b.allow) == 0 {
+		return nil, ErrEmailAllow
+	}
+	if b.imap.Host != "" && b.secret == "" {
+		return nil, ErrEmailNoSecret
+	}
+
+	return b, nil
+}
+
+func emailServerFromConfig(c *viper.Viper, section string) emailServer {
+	return emailServer{
+		Host:     c.GetString(section + ".host"),
+		TLS:      c.GetString(section + ".tls"),
+		Username: c.GetString(section + ".username"),
+		Password: c.GetString(section + ".password"),
+	}
+}
+
+// Forwards new SMS and queues SMS for new emails in the IMAP mailbox
+func (b *emailBridge) Poll() error {
+	errs := []error{}
+
+	if b.watcher != nil {
+		messages, err := b.watcher.Poll()
+		if err == nil && len(messages) > 0 {
+			if err = b.spool(messages); err == nil {
+				err = b.watcher.Ack(messages...)
+			}
+		}
+		errs = append(errs, err, b.deliver())
+	}
+
+	if b.imap.Host != "" {
+		errs = append(errs, b.pollIMAP())
+	}
+
+	return errors.Join(errs...)
+}
+
+// -- SMS to email -- //
+
+func (b *emailBridge) spool(messages []drivers.SMS) error {
+	spool := emailSpool{}
+	return state.Update(emailSpoolFile, &spool, func() error {
+		for _, message := range messages {
+			spool.LastID++
+			spool.Deliveries = append(spool.Deliveries, emailDelivery{ID: strconv.Itoa(spool.LastID), SMS: message, NextAttempt: time.Now()})
+		}
+		return nil
+	})
+}
+
+// Sends due emails in order, stopping at the first one the server doesn't take.
+// Emails the server rejects for good are dropped
+func (b *emailBridge) deliver() error {
+	spool := emailSpool{}
+	if err := state.Load(emailSpoolFile, &spool); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	results := map[string]error{}
+	for _, delivery := range spool.Deliveries {
+		if delivery.NextAttempt.After(now) {
+			continue
+		}
+
+		err := b.send(b.compose(&delivery.SMS))
+		results[delivery.ID] = err
+		if err == nil {
+			cfmt.Printf("{{Emailed:}}::cyan message %s from %s\n", delivery.SMS.ID, delivery.SMS.Sender)
+			continue
+		}
+
+		var smtpErr *smtp.SMTPError
+		if (errors.As(err, &smtpErr) && smtpErr.Code >= 500) || b.policy.exhausted(delivery.Attempts+1) {
+			cfmt.Fprintf(os.Stderr, "{{warning:}}::yellow email for message %s failed for good, dropping it: %v\n", delivery.SMS.ID, err)
+			results[delivery.ID] = nil
+			continue
+		}
+
+		cfmt.Fprintf(os.Stderr, "{{warning:}}::yellow email: %v\n", err)
+		break
+	}
+
+	if len(results) == 0 {
+		return nil
+	}
+
+	return state.Update(emailSpoolFile, &spool, func() error {
+		spool.Deliveries = slices.DeleteFunc(spool.Deliveries, func(delivery emailDelivery) bool {
+			err, ok := results[delivery.ID]
+			return ok && err == nil
+		})
+
+		for i := range spool.Deliveries {
+			delivery := &spool.Deliveries[i]
+			if err := results[delivery.ID]; err != nil {
+				delivery.Attempts++
+				delivery.NextAttempt = now.Add(b.policy.delay(delivery.Attempts))
+				delivery.LastError = err.Error()
+			}
+		}
+		return nil
+	})
+}
+
+// Builds the email for a message. Emails from the same number share a thread,
+// replying to one sends an SMS back
+func (b *emailBridge) compose(message *drivers.SMS) []byte {
+	id := sha256.Sum256([]byte(modemKey(b.modem) + "\x00" + message.ID + "\x00" + message.Sender + "\x00" + message.Time.String()))
+	thread := sha256.Sum256([]byte(message.Sender))
+	threadID := fmt.Sprintf("<thread-%s@%s>", hex.EncodeToString(thread[:8]), b.domain)
+
+	buf := bytes.Buffer{}
+	fmt.Fprintf(&buf, "From: %s\r\n", b.from)
+	fmt.Fprintf(&buf, "To: %s\r\n", strings.Join(b.to, ", "))
+	// Alphanumeric senders can't be replied to
+	if phone, err := drivers.NormalizePhone(message.Sender); err == nil {
+		fmt.Fprintf(&buf, "Reply-To: %s@%s\r\n", phone, b.domain)
+	}
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", "SMS from "+message.Sender))
+	fmt.Fprintf(&buf, "Date: %s\r\n", message.Time.Format(time.RFC1123Z))
+	fmt.Fprintf(&buf, "Message-ID: <sms-%s@%s>\r\n", hex.EncodeToString(id[:8]), b.domain)
+	fmt.Fprintf(&buf, "In-Reply-To: %s\r\nReferences: %s\r\n", threadID, threadID)
+	fmt.Fprintf(&buf, "X-SMS-Number: %s\r\n", message.Sender)
+	buf.WriteString("MIME-Version: 1.0\r\nContent-Type: text/plain; charset=utf-8\r\nContent-Transfer-Encoding: quoted-printable\r\n\r\n")
+
+	body := quotedprintable.NewWriter(&buf)
+	body.Write([]byte(strings.ReplaceAll(message.Message, "\n", "\r\n")))
+	body.Close()
+	buf.WriteString("\r\n")
+
+	return buf.Bytes()
+}
+
+func (b *emailBridge) send(message []byte) error {
+	c, err := b.dialSMTP()
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	if b.smtp.Username != "" {
+		if err := c.Auth(sasl.NewPlainClient("", b.smtp.Username, b.smtp.Password)); err != nil {
+			return err
+		}
+	}
+
+	if err := c.Mail(b.from, nil); err != nil {
+		return err
+	}
+	for _, to := range b.to {
+		if err := c.Rcpt(to); err != nil {
+			return err
+		}
+	}
+
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(message); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	return c.Quit()
+}
+
+func (b *emailBridge) dialSMTP() (*smtp.Client, error) {
+	conn, err := b.dial(b.smtp)
+	if err != nil {
+		return nil, err
+	}
+
+	host, _, _ := net.SplitHostPort(b.smtp.Host)
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	if b.smtp.TLS == "starttls" {
+		if err := c.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			c.Close()
+			return nil, err
+		}
+	}
+
+	return c, nil
+}
+
+// Opens a connection, wrapped in TLS right away if the server wants that
+func (b *emailBridge) dial(server emailServer) (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: b.timeout}
+
+	var conn net.Conn
+	var err error
+	if server.TLS == "tls" {
+		host, _, _ := net.SplitHostPort(server.Host)
+		conn, err = tls.DialWithDialer(dialer, "tcp", server.Host, &tls.Config{ServerName: host})
+	} else {
+		conn, err = dialer.Dial("tcp", server.Host)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Covers the whole session, which is short
+	conn.SetDeadline(time.Now().Add(b.timeout))
+	return conn, nil
+}
+
+// -- Email to SMS -- //
+
+// Queues SMS for unseen emails in the mailbox. Emails are marked seen even if
+// they were rejected, so they aren't looked at again
+func (b *emailBridge) pollIMAP() error {
+	conn, err := b.dial(b.imap)
+	if err != nil {
+		return err
+	}
+
+	c, err := imapclient.New(conn)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Logout()
+	c.Timeout = b.timeout
+
+	if b.imap.TLS == "starttls" {
+		host, _, _ := net.SplitHostPort(b.imap.Host)
+		if err := c.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			return err
+		}
+	}
+	if b.imap.Username != "" {
+		if err := c.Login(b.imap.Username, b.imap.Password); err != nil {
+			return err
+		}
+	}
+
+	if _, err := c.Select(b.mailbox, false); err != nil {
+		return err
+	}
+
+	criteria := imap.NewSearchCriteria()
+	criteria.WithoutFlags = []string{imap.SeenFlag}
+	uids, err := c.UidSearch(criteria)
+	if err != nil || len(uids) == 0 {
+		return err
+	}
+
+	unseen := &imap.SeqSet{}
+	unseen.AddNum(uids...)
+	section := &imap.BodySectionName{Peek: true}
+
+	fetched := make(chan *imap.Message, len(uids))
+	if err := c.UidFetch(unseen, []imap.FetchItem{imap.FetchUid, section.FetchItem()}, fetched); err != nil {
+		return err
+	}
+
+	seen := &imap.SeqSet{}
+	for message := range fetched {
+		body := message.GetBody(section)
+		if body == nil {
+			continue
+		}
+
+		b.handle("", nil, body)
+		seen.AddNum(message.Uid)
+	}
+
+	if seen.Empty() {
+		return nil
+	}
+	return c.UidStore(seen, imap.FormatFlagsOp(imap.AddFlags, true), []interface{}{imap.SeenFlag}, nil)
+}
+
+// Starts the SMTP listener in the background. It takes no logins and relies on
+// email.allow, so it should only listen where untrusted hosts can't reach it
+func (b *emailBridge) Listen() error {
+	if b.listen == "" {
+		return nil
+	}
+
+	l, err := net.Listen("tcp", b.listen)
+	if err != nil {
+		return fmt.Errorf("email listener: %w", err)
+	}
+
+	server := smtp.NewServer(&emailBackend{bridge: b})
+	server.Domain = b.domain
+	server.AuthDisabled = true
+	server.MaxMessageBytes = 1024 * 1024
+	server.MaxRecipients = 50
+	server.ReadTimeout = time.Minute
+	server.WriteTimeout = time.Minute
+
+	go func() {
+		if err := server.Serve(l); err != nil {
+			cfmt.Fprintf(os.Stderr, "{{warning:}}::yellow email listener stopped: %v\n", err)
+		}
+	}()
+
+	cfmt.Printf("{{Email:}}::cyan listening on %s\n", l.Addr())
+	return nil
+}
+
+// Queues an SMS for every SMS address the email was sent to. The envelope
+// sender and recipients are used when known, headers otherwise
+func (b *emailBridge) handle(envelopeFrom string, envelopeTo []string, r io.Reader) ([]string, error) {
+	ids, err := b.queueEmail(envelopeFrom, envelopeTo, r)
+	if err != nil {
+		cfmt.Fprintf(os.Stderr, "{{warning:}}::yellow email rejected: %v\n", err)
+	}
+	return ids, err
+}
+
+func (b *emailBridge) queueEmail(envelopeFrom string, envelopeTo []string, r io.Reader) ([]string, error) {
+	message, err := mail.ReadMessage(r)
+	if err != nil {
+		return nil, fmt.Errorf("invalid email: %w", err)
+	}
+
+	from, err := mail.ParseAddress(message.Header.Get("From"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid From: %w", err)
+	}
+	if !b.allowed(from.Address) || (envelopeFrom != "" && !b.allowed(envelopeFrom)) {
+		return nil, fmt.Errorf("%w: %s", ErrEmailSender, from.Address)
+	}
+	if !b.hasSecret(message.Header.Get("Subject")) {
+		return nil, ErrEmailSecret
+	}
+
+	recipients := envelopeTo
+	if len(recipients) == 0 {
+		for _, header := range []string{"To", "Cc"} {
+			addresses, err := message.Header.AddressList(header)
+			if err != nil && !errors.Is(err, mail.ErrHeaderNotPresent) {
+				return nil, fmt.Errorf("invalid %s: %w", header, err)
+			}
+			for _, address := range addresses {
+				recipients = append(recipients, address.Address)
+			}
+		}
+	}
+
+	phones := []string{}
+	for _, recipient := range recipients {
+		if phone, ok := b.phone(recipient); ok && !slices.Contains(phones, phone) {
+			phones = append(phones, phone)
+		}
+	}
+	if len(phones) == 0 {
+		return nil, ErrEmailRecipient
+	}
+
+	text, err := emailText(message.Header, message.Body)
+	if err != nil {
+		return nil, err
+	}
+	if err := validate.Var(text, "required"); err != nil {
+		return nil, ErrEmptyMessage
+	}
+	if err := validate.Var(text, "utf8"); err != nil {
+		return nil, ErrInvalidMessage
+	}
+	if _, err := drivers.CountSMS(text, drivers.SMSEncodingAuto); err != nil {
+		return nil, err
+	}
+
+	ids := []string{}
+	for _, phone := range phones {
+		id, err := enqueueSMS(phone, text, drivers.SMSSendOptions{})
+		if err != nil {
+			return ids, err
+		}
+
+		cfmt.Printf("{{Email:}}::cyan from %s queued as %s for %s\n", from.Address, id, phone)
+		ids = append(ids, id)
+	}
+
+	return ids, nil
+}
+
+// Addresses in email.allow match exactly, ones starting with @ match a whole domain.
+// Only the From header is known for polled emails and anyone can set it, so
+// those have to carry email.secret as well
+func (b *emailBridge) allowed(address string) bool {
+	address = strings.ToLower(address)
+	return slices.ContainsFunc(b.allow, func(allowed string) bool {
+		allowed = strings.ToLower(allowed)
+		return address == allowed || (strings.HasPrefix(allowed, "@") && strings.HasSuffix(address, allowed))
+	})
+}
+
+// Tells if email.secret is a word of the subject, when one is set
+func (b *emailBridge) hasSecret(subject string) bool {
+	if b.secret == "" {
+		return true
+	}
+
+	if decoded, err := new(mime.WordDecoder).DecodeHeader(subject); err == nil {
+		subject = decoded
+	}
+	return slices.ContainsFunc(strings.Fields(subject), func(word string) bool {
+		return subtle.ConstantTimeCompare([]byte(word), []byte(b.secret)) == 1
+	})
+}
+
+// Returns the number an address like +4915...@sms.local is for
+func (b *emailBridge) phone(address string) (string, bool) {
+	at := strings.LastIndex(address, "@")
+	if at < 0 || !strings.EqualFold(address[at+1:], b.domain) {
+		return "", false
+	}
+
+	phone, err := drivers.NormalizePhone(address[:at])
+	if err != nil {
+		return "", false
+	}
+	return phone, true
+}
+
+// Returns the first plain text part of an email, without quoted replies and
+// the signature
+func emailText(header mail.Header, body io.Reader) (string, error) {
+	mediaType, params, err := mime.ParseMediaType(header.Get("Content-Type"))
+	if err != nil {
+		// Plain text if missing
+		mediaType, params = "text/plain", map[string]string{}
+	}
+
+	if strings.HasPrefix(mediaType, "multipart/") {
+		parts := multipart.NewReader(body, params["boundary"])
+		for {
+			part, err := parts.NextPart()
+			if err == io.EOF {
+				return "", nil
+			}
+			if err != nil {
+				return "", fmt.Errorf("invalid email body: %w", err)
+			}
+
+			text, err := emailText(mail.Header(part.Header), part)
+			if err != nil || text != "" {
+				return text, err
+			}
+		}
+	}
+	if mediaType != "text/plain" {
+		return "", nil
+	}
+
+	switch strings.ToLower(header.Get("Content-Transfer-Encoding")) {
+	case "quoted-printable":
+		body = quotedprintable.NewReader(body)
+	case "base64":
+		body = base64.NewDecoder(base64.StdEncoding, body)
+	}
+
+	raw, err := readMessageBytes(body)
+	if err != nil {
+		return "", err
+	}
+
+	switch charset := strings.ToLower(params["charset"]); charset {
+	case "", "utf-8", "us-ascii":
+	case "iso-8859-1", "latin1":
+		runes := make([]rune, len(raw))
+		for i, c := range raw {
+			runes[i] = rune(c)
+		}
+		raw = []byte(string(runes))
+	default:
+		return "", fmt.Errorf("unsupported email charset %q, use UTF-8", charset)
+	}
+
+	return stripEmailReply(string(raw)), nil
+}
+
+func stripEmailReply(text string) string {
+	lines := strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+
+	kept := []string{}
+	for _, line := range lines {
+		// Quoted-printable decoding drops the space of "-- "
+		if strings.TrimRight(line, " ") == "--" {
+			break
+		}
+		if strings.HasPrefix(line, ">") {
+			// Drop the "On ..., someone wrote:" line introducing the quote
+			for len(kept) > 0 && strings.TrimSpace(kept[len(kept)-1]) == "" {
+				kept = kept[:len(kept)-1]
+			}
+			if len(kept) > 0 && strings.HasSuffix(strings.TrimSpace(kept[len(kept)-1]), "wrote:") {
+				kept = kept[:len(kept)-1]
+			}
+			continue
+		}
+		kept = append(kept, line)
+	}
+
+	return strings.TrimSpace(strings.Join(kept, "\n"))
+}
+
+// -- SMTP listener -- //
+
+type (
+	emailBackend struct {
+		bridge *emailBridge
+	}
+
+	emailSession struct {
+		bridge *emailBridge
+		from   string
+		to     []string
+	}
+)
+
+func (be *emailBackend) Login(state *smtp.ConnectionState, username, password string) (smtp.Session, error) {
+	return nil, smtp.ErrAuthUnsupported
+}
+
+func (be *emailBackend) AnonymousLogin(state *smtp.ConnectionState) (smtp.Session, error) {
+	return &emailSession{bridge: be.bridge}, nil
+}
+
+func (s *emailSession) Mail(from string, opts smtp.MailOptions) error {
+	if !s.bridge.allowed(from) {
+		return &smtp.SMTPError{Code: 550, EnhancedCode: smtp.EnhancedCode{5, 7, 1}, Message: ErrEmailSender.Error()}
+	}
+
+	s.from = from
+	return nil
+}
+
+func (s *emailSession) Rcpt(to string) error {
+	if _, ok := s.bridge.phone(to); !ok {
+		return &smtp.SMTPError{Code: 550, EnhancedCode: smtp.EnhancedCode{5, 1, 1}, Message: "not an SMS address"}
+	}
+
+	s.to = append(s.to, to)
+	return nil
+}
+
+func (s *emailSession) Data(r io.Reader) error {
+	if _, err := s.bridge.handle(s.from, s.to, r); err != nil {
+		return &smtp.SMTPError{Code: 554, EnhancedCode: smtp.EnhancedCode{5, 6, 0}, Message: err.Error()}
+	}
+	return nil
+}
+
+func (s *emailSession) Reset() {
+	s.from, s.to = "", nil
+}
+
+func (s *emailSession) Logout() error {
+	return nil
+}
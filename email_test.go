@@ -0,0 +1,430 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"log/slog"
+	"mime"
+	"net"
+	"net/mail"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/brokenCursor/usb-modem-cli/config"
+	"github.com/brokenCursor/usb-modem-cli/drivers"
+	"github.com/brokenCursor/usb-modem-cli/state"
+	"github.com/emersion/go-imap/backend/memory"
+	imapclient "github.com/emersion/go-imap/client"
+	imapserver "github.com/emersion/go-imap/server"
+	"github.com/emersion/go-smtp"
+)
+
+func init() {
+	validate = newValidator()
+}
+
+// Returns a bridge with its state in a fresh directory
+func newTestBridge(t *testing.T) *emailBridge {
+	t.Helper()
+	config.Set("state.dir", t.TempDir())
+	config.Set("phone.region", "")
+	config.Set("modem.host", "127.0.0.1")
+
+	modem, err := drivers.GetModemDriver("dummy", config.Sub("modem"), slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &emailBridge{
+		modem:   modem,
+		from:    "modem@example.com",
+		to:      []string{"me@example.com"},
+		domain:  "sms.local",
+		allow:   []string{"me@example.com", "@example.org"},
+		secret:  "t0ken",
+		policy:  retryPolicy{Backoff: time.Second, MaxBackoff: time.Second},
+		timeout: 5 * time.Second,
+	}
+}
+
+func loadOutbox(t *testing.T) []outboxSMS {
+	t.Helper()
+	outbox := smsOutbox{}
+	if err := state.Load(outboxFile, &outbox); err != nil {
+		t.Fatal(err)
+	}
+	return outbox.Messages
+}
+
+const testEmail = "From: Me <me@example.com>\r\n" +
+	"To: <+4917012345@sms.local>, other@example.com\r\n" +
+	"Subject: Re: SMS from +4917012345 t0ken\r\n" +
+	"Content-Type: text/plain; charset=utf-8\r\n" +
+	"\r\n" +
+	"See you at 8\r\n"
+
+func TestCompose(t *testing.T) {
+	b := newTestBridge(t)
+	sms := drivers.SMS{ID: "7", Sender: "+4917012345", Time: time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC), Message: "Grüße\nbis später"}
+
+	message, err := mail.ReadMessage(bytes.NewReader(b.compose(&sms)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	subject, _ := new(mime.WordDecoder).DecodeHeader(message.Header.Get("Subject"))
+	for header, want := range map[string]string{
+		"From":         "modem@example.com",
+		"To":           "me@example.com",
+		"Reply-To":     "+4917012345@sms.local",
+		"X-SMS-Number": "+4917012345",
+		"Date":         "Fri, 02 Jan 2026 03:04:05 +0000",
+	} {
+		if got := message.Header.Get(header); got != want {
+			t.Errorf("%s = %q, want %q", header, got, want)
+		}
+	}
+	if subject != "SMS from +4917012345" {
+		t.Errorf("Subject = %q", subject)
+	}
+
+	text, err := emailText(message.Header, message.Body)
+	if err != nil || text != "Grüße\nbis später" {
+		t.Errorf("body = %q, %v", text, err)
+	}
+
+	// Threads are kept per number, messages get their own ID
+	other := b.compose(&drivers.SMS{ID: "8", Sender: "+4917012345", Time: sms.Time, Message: "again"})
+	again, _ := mail.ReadMessage(bytes.NewReader(other))
+	if again.Header.Get("In-Reply-To") != message.Header.Get("In-Reply-To") {
+		t.Error("messages from the same number are in different threads")
+	}
+	if again.Header.Get("Message-ID") == message.Header.Get("Message-ID") {
+		t.Error("messages share a Message-ID")
+	}
+
+	// Names can't be replied to
+	named, _ := mail.ReadMessage(bytes.NewReader(b.compose(&drivers.SMS{ID: "9", Sender: "Bank", Message: "code"})))
+	if reply := named.Header.Get("Reply-To"); reply != "" {
+		t.Errorf("Reply-To = %q for an alphanumeric sender", reply)
+	}
+}
+
+func TestQueueEmail(t *testing.T) {
+	tests := []struct {
+		name         string
+		email        string
+		envelopeFrom string
+		envelopeTo   []string
+		want         []string // Phones queued for
+		err          error
+	}{
+		{name: "headers", email: testEmail, want: []string{"+4917012345"}},
+		{name: "envelope", email: testEmail, envelopeFrom: "me@example.com", envelopeTo: []string{"0049 170 99999@SMS.local"}, want: []string{"+4917099999"}},
+		{name: "domain allowed", email: strings.Replace(testEmail, "me@example.com", "anyone@example.org", 1), want: []string{"+4917012345"}},
+		{name: "sender", email: strings.Replace(testEmail, "me@example.com", "evil@example.net", 1), err: ErrEmailSender},
+		{name: "envelope sender", email: testEmail, envelopeFrom: "evil@example.net", err: ErrEmailSender},
+		{name: "secret", email: strings.Replace(testEmail, " t0ken", "", 1), err: ErrEmailSecret},
+		{name: "secret in a word", email: strings.Replace(testEmail, " t0ken", " xt0ken", 1), err: ErrEmailSecret},
+		{name: "recipient", email: strings.Replace(testEmail, "<+4917012345@sms.local>, ", "", 1), err: ErrEmailRecipient},
+		{name: "empty", email: strings.Replace(testEmail, "See you at 8", "> quoted only", 1), err: ErrEmptyMessage},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			b := newTestBridge(t)
+
+			ids, err := b.queueEmail(test.envelopeFrom, test.envelopeTo, strings.NewReader(test.email))
+			if !errors.Is(err, test.err) {
+				t.Fatalf("err = %v, want %v", err, test.err)
+			}
+
+			queued := loadOutbox(t)
+			if len(ids) != len(test.want) || len(queued) != len(test.want) {
+				t.Fatalf("queued %v (%d in outbox), want %v", ids, len(queued), test.want)
+			}
+			for i := range queued {
+				if queued[i].Phone != test.want[i] || queued[i].Message != "See you at 8" {
+					t.Errorf("queued %q for %s", queued[i].Message, queued[i].Phone)
+				}
+			}
+		})
+	}
+}
+
+func TestEmailText(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		body   string
+		want   string
+		err    error
+	}{
+		{name: "plain", body: "hello\r\n", want: "hello"},
+		{name: "latin1", header: "Content-Type: text/plain; charset=iso-8859-1\r\nContent-Transfer-Encoding: quoted-printable\r\n", body: "Gr=FC=DFe\r\n", want: "Grüße"},
+		{name: "base64", header: "Content-Transfer-Encoding: base64\r\n", body: "aGVsbG8=\r\n", want: "hello"},
+		{name: "html only", header: "Content-Type: text/html\r\n", body: "<p>hello</p>"},
+		{name: "charset", header: "Content-Type: text/plain; charset=koi8-r\r\n", body: "hello", err: errors.New("unsupported")},
+		{name: "too large", body: strings.Repeat("a", maxMessageSize+1), err: ErrMessageTooLarge},
+		{
+			name:   "multipart",
+			header: "Content-Type: multipart/alternative; boundary=XX\r\n",
+			body:   "--XX\r\nContent-Type: text/html\r\n\r\n<p>html</p>\r\n--XX\r\nContent-Type: text/plain\r\n\r\nplain\r\n--XX--\r\n",
+			want:   "plain",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			message, err := mail.ReadMessage(strings.NewReader(test.header + "\r\n" + test.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			text, err := emailText(message.Header, message.Body)
+			switch {
+			case test.err == nil && err != nil:
+				t.Fatalf("err = %v", err)
+			case test.err != nil && (err == nil || (!errors.Is(err, test.err) && !strings.Contains(err.Error(), test.err.Error()))):
+				t.Fatalf("err = %v, want %v", err, test.err)
+			}
+			if text != test.want {
+				t.Errorf("text = %q, want %q", text, test.want)
+			}
+		})
+	}
+}
+
+func TestStripEmailReply(t *testing.T) {
+	tests := map[string]string{
+		"hello":                                  "hello",
+		"hello\n\nOn Mon, someone wrote:\n> old": "hello",
+		"hello\r\n> old\r\nafter":                "hello\nafter",
+		"hello\n-- \nsig":                        "hello",
+		"hello\n--\nsig":                         "hello",
+		"hello\n---\nnot a signature":            "hello\n---\nnot a signature",
+		"  \nhello\n\n":                          "hello",
+		"I wrote:\nthis myself":                  "I wrote:\nthis myself",
+	}
+
+	for text, want := range tests {
+		if got := stripEmailReply(text); got != want {
+			t.Errorf("stripEmailReply(%q) = %q, want %q", text, got, want)
+		}
+	}
+}
+
+func TestAllowedAndPhone(t *testing.T) {
+	b := newTestBridge(t)
+
+	for address, want := range map[string]bool{
+		"me@example.com":      true,
+		"ME@Example.com":      true,
+		"you@example.org":     true,
+		"me@example.com.evil": false,
+		"you@sub.example.org": false,
+		"you@notexample.org":  false,
+		"other@example.com":   false,
+	} {
+		if got := b.allowed(address); got != want {
+			t.Errorf("allowed(%q) = %v", address, got)
+		}
+	}
+
+	for address, want := range map[string]string{
+		"+4917012345@sms.local":   "+4917012345",
+		"004917012345@SMS.LOCAL":  "+4917012345",
+		"22222@sms.local":         "22222",
+		"+4917012345@example.com": "",
+		"bank@sms.local":          "",
+		"+4917012345":             "",
+	} {
+		phone, ok := b.phone(address)
+		if phone != want || ok != (want != "") {
+			t.Errorf("phone(%q) = %q, %v", address, phone, ok)
+		}
+	}
+}
+
+// Stands in for the mail server the bridge sends to
+type testSMTPBackend struct {
+	mu       sync.Mutex
+	received []string
+	reject   *smtp.SMTPError
+}
+
+type testSMTPSession struct {
+	backend *testSMTPBackend
+}
+
+func (be *testSMTPBackend) Login(state *smtp.ConnectionState, username, password string) (smtp.Session, error) {
+	return nil, smtp.ErrAuthUnsupported
+}
+
+func (be *testSMTPBackend) AnonymousLogin(state *smtp.ConnectionState) (smtp.Session, error) {
+	return &testSMTPSession{backend: be}, nil
+}
+
+func (s *testSMTPSession) Mail(from string, opts smtp.MailOptions) error { return nil }
+
+func (s *testSMTPSession) Rcpt(to string) error {
+	if s.backend.reject != nil {
+		return s.backend.reject
+	}
+	return nil
+}
+
+func (s *testSMTPSession) Data(r io.Reader) error {
+	data, err := io.ReadAll(r)
+	s.backend.mu.Lock()
+	s.backend.received = append(s.backend.received, string(data))
+	s.backend.mu.Unlock()
+	return err
+}
+
+func (s *testSMTPSession) Reset()        {}
+func (s *testSMTPSession) Logout() error { return nil }
+
+func listenLoopback(t *testing.T) net.Listener {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return l
+}
+
+func TestDeliver(t *testing.T) {
+	b := newTestBridge(t)
+	backend := &testSMTPBackend{}
+
+	l := listenLoopback(t)
+	server := smtp.NewServer(backend)
+	server.AuthDisabled = true
+	go server.Serve(l)
+	defer server.Close()
+	b.smtp = emailServer{Host: l.Addr().String(), TLS: "none"}
+
+	sms := []drivers.SMS{{ID: "1", Sender: "+4917012345", Message: "first"}, {ID: "2", Sender: "Bank", Message: "second"}}
+	if err := b.spool(sms); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.deliver(); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(backend.received) != 2 || !strings.Contains(backend.received[0], "first") || !strings.Contains(backend.received[1], "second") {
+		t.Fatalf("received %q", backend.received)
+	}
+
+	spool := emailSpool{}
+	if err := state.Load(emailSpoolFile, &spool); err != nil || len(spool.Deliveries) != 0 {
+		t.Fatalf("spool = %+v, %v", spool, err)
+	}
+
+	// Temporary failures are kept for later, permanent ones dropped
+	backend.reject = &smtp.SMTPError{Code: 451, Message: "try later"}
+	b.spool(sms[:1])
+	b.deliver()
+	state.Load(emailSpoolFile, &spool)
+	if len(spool.Deliveries) != 1 || spool.Deliveries[0].Attempts != 1 {
+		t.Fatalf("spool after a temporary failure = %+v", spool)
+	}
+
+	backend.reject = &smtp.SMTPError{Code: 550, Message: "no"}
+	spool.Deliveries[0].NextAttempt = time.Time{}
+	state.Save(emailSpoolFile, spool)
+	b.deliver()
+	spool = emailSpool{}
+	state.Load(emailSpoolFile, &spool)
+	if len(spool.Deliveries) != 0 {
+		t.Fatalf("spool after a permanent failure = %+v", spool)
+	}
+}
+
+func TestListener(t *testing.T) {
+	b := newTestBridge(t)
+
+	// Listen doesn't say which port it got, so find a free one
+	l := listenLoopback(t)
+	b.listen = l.Addr().String()
+	l.Close()
+	if err := b.Listen(); err != nil {
+		t.Fatal(err)
+	}
+
+	err := smtp.SendMail(b.listen, nil, "me@example.com", []string{"+4917012345@sms.local"}, strings.NewReader(testEmail))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var smtpErr *smtp.SMTPError
+	err = smtp.SendMail(b.listen, nil, "evil@example.net", []string{"+4917012345@sms.local"}, strings.NewReader(testEmail))
+	if !errors.As(err, &smtpErr) || smtpErr.Code != 550 {
+		t.Errorf("stranger: err = %v, want 550", err)
+	}
+	err = smtp.SendMail(b.listen, nil, "me@example.com", []string{"me@example.com"}, strings.NewReader(testEmail))
+	if !errors.As(err, &smtpErr) || smtpErr.Code != 550 {
+		t.Errorf("not an SMS address: err = %v, want 550", err)
+	}
+	err = smtp.SendMail(b.listen, nil, "me@example.com", []string{"+4917012345@sms.local"}, strings.NewReader(strings.Replace(testEmail, " t0ken", "", 1)))
+	if !errors.As(err, &smtpErr) || smtpErr.Code != 554 {
+		t.Errorf("no secret: err = %v, want 554", err)
+	}
+
+	queued := loadOutbox(t)
+	if len(queued) != 1 || queued[0].Phone != "+4917012345" || queued[0].Message != "See you at 8" {
+		t.Errorf("outbox = %+v", queued)
+	}
+}
+
+func TestPollIMAP(t *testing.T) {
+	b := newTestBridge(t)
+
+	// Comes with username/password and one seen email in INBOX
+	l := listenLoopback(t)
+	server := imapserver.New(memory.New())
+	server.AllowInsecureAuth = true
+	go server.Serve(l)
+	defer server.Close()
+
+	b.imap = emailServer{Host: l.Addr().String(), TLS: "none", Username: "username", Password: "password"}
+	b.mailbox = "INBOX"
+
+	c, err := imapclient.Dial(b.imap.Host)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Logout()
+	if err := c.Login("username", "password"); err != nil {
+		t.Fatal(err)
+	}
+	for _, email := range []string{
+		testEmail,
+		strings.Replace(testEmail, " t0ken", "", 1),                         // Forged From without the secret
+		strings.Replace(testEmail, "me@example.com", "evil@example.net", 1), // Stranger
+	} {
+		if err := c.Append("INBOX", nil, time.Now(), bytes.NewBufferString(email)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := b.pollIMAP(); err != nil {
+		t.Fatal(err)
+	}
+	queued := loadOutbox(t)
+	if len(queued) != 1 || queued[0].Phone != "+4917012345" || queued[0].Message != "See you at 8" {
+		t.Fatalf("outbox = %+v", queued)
+	}
+
+	// Everything was marked seen, rejected emails too
+	if err := b.pollIMAP(); err != nil {
+		t.Fatal(err)
+	}
+	if queued := loadOutbox(t); len(queued) != 1 {
+		t.Errorf("emails were queued again: %+v", queued)
+	}
+}
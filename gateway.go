@@ -0,0 +1,275 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/brokenCursor/usb-modem-cli/config"
+	"github.com/brokenCursor/usb-modem-cli/drivers"
+	"github.com/brokenCursor/usb-modem-cli/state"
+	"github.com/i582/cfmt/cmd/cfmt"
+)
+
+const (
+	gatewaySeqFile = "sms-gateway-seq.json"
+	gatewayLogFile = "sms-gateway.log"
+)
+
+// Longest reply sent back, longer script output is cut
+const maxReplyLength = 300
+
+var ErrGatewaySecret = errors.New("gateway.secret must be set to use the SMS gateway")
+var ErrUnknownCommand = errors.New("unknown command, send help for a list")
+
+// Runs commands sent by SMS. A command looks like "<secret> <seq> <command> [args]",
+// where seq has to grow with every command from a sender, so a message can't be replayed
+type smsGateway struct {
+	modem   drivers.BaseModem
+	sms     drivers.ModemSMS
+	watcher *smsWatcher
+	secret  string
+	allow   []string
+	scripts map[string]string
+	timeout time.Duration
+}
+
+// Entry of the command log
+type gatewayEvent struct {
+	Time    time.Time `json:"time"`
+	Sender  string    `json:"sender"`
+	ID      string    `json:"id"`
+	Command string    `json:"command,omitempty"`
+	Result  string    `json:"result"`
+}
+
+func newSMSGateway(modem drivers.BaseModem, sms drivers.ModemSMS) (*smsGateway, error) {
+	c := config.Sub("gateway")
+	if c.GetString("secret") == "" {
+		return nil, ErrGatewaySecret
+	}
+
+	// Senders are compared in E.164, so the list may use any notation
+	allow := []string{}
+	for _, number := range c.GetStringSlice("allow") {
+		normalized, err := drivers.NormalizePhone(number)
+		if err != nil {
+			return nil, fmt.Errorf("gateway.allow: %w", err)
+		}
+		allow = append(allow, normalized)
+	}
+
+	watcher, err := newSMSWatcher(sms, "sms-gateway", config.Sub("modem").GetString("host"))
+	if err != nil {
+		return nil, err
+	}
+
+	return &smsGateway{
+		modem:   modem,
+		sms:     sms,
+		watcher: watcher,
+		secret:  c.GetString("secret"),
+		allow:   allow,
+		scripts: c.GetStringMapString("scripts"),
+		timeout: c.GetDuration("script_timeout"),
+	}, nil
+}
+
+func (g *smsGateway) Poll() error {
+	messages, err := g.watcher.Poll()
+	if err != nil {
+		return err
+	}
+
+	for i := range messages {
+		g.handle(&messages[i])
+		if err := g.watcher.Ack(messages[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (g *smsGateway) handle(message *drivers.SMS) {
+	event := gatewayEvent{Time: time.Now(), Sender: message.Sender, ID: message.ID}
+	defer g.log(&event)
+
+	// Strangers get no reply, so they can't tell a gateway is listening
+	sender := drivers.DisplayPhone(message.Sender)
+	if !slices.Contains(g.allow, sender) {
+		event.Result = "rejected: sender not allowed"
+		return
+	}
+
+	fields := strings.Fields(message.Message)
+	if len(fields) < 3 || subtle.ConstantTimeCompare([]byte(fields[0]), []byte(g.secret)) != 1 {
+		event.Result = "rejected: wrong secret"
+		return
+	}
+	event.Command = strings.Join(fields[2:], " ")
+
+	seq, err := strconv.Atoi(fields[1])
+	if err != nil {
+		event.Result = "rejected: invalid sequence number"
+		return
+	}
+	if err := g.claim(sender, seq); err != nil {
+		event.Result = "rejected: " + err.Error()
+		return
+	}
+
+	reply, after, err := g.execute(fields[2:])
+	if err != nil {
+		reply = "error: " + err.Error()
+	}
+	event.Result = reply
+
+	if len([]rune(reply)) > maxReplyLength {
+		reply = string([]rune(reply)[:maxReplyLength])
+	}
+	if _, err := g.sms.SendSMS(message.Sender, reply, drivers.SMSSendOptions{}); err != nil {
+		event.Result += fmt.Sprintf(" (reply failed: %v)", err)
+	}
+
+	// Some commands cut the modem off, so they run after replying
+	if after != nil {
+		if err := after(); err != nil {
+			event.Result += fmt.Sprintf(" (failed: %v)", err)
+		}
+	}
+}
+
+// Records the sequence number, failing if it isn't above the last one seen
+func (g *smsGateway) claim(sender string, seq int) error {
+	last := map[string]int{}
+	return state.Update(gatewaySeqFile, &last, func() error {
+		if seq <= last[sender] {
+			return fmt.Errorf("replayed sequence number %d, last was %d", seq, last[sender])
+		}
+		last[sender] = seq
+		return nil
+	})
+}
+
+// Returns the reply and an action to run once it was sent
+func (g *smsGateway) execute(command []string) (string, func() error, error) {
+	switch strings.ToLower(command[0]) {
+	case "help":
+		return "commands: conn up|down|status, reboot, signal, ip, run <script>", nil, nil
+	case "conn":
+		cell, ok := g.modem.(drivers.ModemCell)
+		if !ok {
+			return "", nil, DriverSupportError{Driver: g.modem, Function: "cell connection"}
+		}
+		if len(command) != 2 {
+			return "", nil, ErrUnknownCommand
+		}
+
+		switch strings.ToLower(command[1]) {
+		case "up":
+			return "connecting", nil, cell.ConnectCell()
+		case "down":
+			return "disconnecting", nil, cell.DisconnectCell()
+		case "status":
+			status, err := cell.GetCellConnStatus()
+			if err != nil {
+				return "", nil, err
+			}
+			states := []string{"down", "disconnecting", "connecting", "up"}
+			if int(status.State) >= len(states) || status.State < 0 {
+				return "connection state unknown", nil, nil
+			}
+			return "connection " + states[status.State], nil, nil
+		}
+	case "reboot":
+		device, ok := g.modem.(drivers.ModemDevice)
+		if !ok {
+			return "", nil, DriverSupportError{Driver: g.modem, Function: "rebooting"}
+		}
+		return "rebooting", device.Reboot, nil
+	case "signal", "ip":
+		cell, ok := g.modem.(drivers.ModemCellInfo)
+		if !ok {
+			return "", nil, DriverSupportError{Driver: g.modem, Function: "cell info"}
+		}
+
+		info, err := cell.GetCellInfo()
+		if err != nil {
+			return "", nil, err
+		}
+
+		if strings.EqualFold(command[0], "ip") {
+			return fmt.Sprintf("IPv4 %s IPv6 %s", info.IPv4, info.IPv6), nil, nil
+		}
+		return fmt.Sprintf("%s %s %d/5 RSSI %d RSRP %d RSRQ %d SINR %d", info.Operator, info.NetworkType, info.SignalBars, info.RSSI, info.RSRP, info.RSRQ, info.SINR), nil, nil
+	case "run":
+		if len(command) != 2 {
+			return "", nil, ErrUnknownCommand
+		}
+
+		// Only scripts named in config can run, arguments aren't passed on
+		script, ok := g.scripts[strings.ToLower(command[1])]
+		if !ok {
+			return "", nil, fmt.Errorf("no script named %q", command[1])
+		}
+		return g.runScript(script)
+	}
+
+	return "", nil, ErrUnknownCommand
+}
+
+func (g *smsGateway) runScript(script string) (string, func() error, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), g.timeout)
+	defer cancel()
+
+	// Children left running by the script would keep the output open past the timeout
+	cmd := shellCommand(ctx, script)
+	cmd.WaitDelay = time.Second
+
+	output, err := cmd.CombinedOutput()
+	if ctx.Err() != nil {
+		return "", nil, fmt.Errorf("script timed out after %s", g.timeout)
+	}
+	if err != nil && !errors.Is(err, exec.ErrWaitDelay) {
+		return "", nil, fmt.Errorf("%w: %s", err, strings.TrimSpace(string(output)))
+	}
+
+	return strings.TrimSpace(string(output)), nil, nil
+}
+
+// Appends the event to the command log and prints it
+func (g *smsGateway) log(event *gatewayEvent) {
+	cfmt.Printf("{{Gateway:}}::cyan %s %q: %s\n", event.Sender, event.Command, event.Result)
+
+	data, err := json.Marshal(event)
+	if err == nil {
+		err = appendStateLine(gatewayLogFile, data)
+	}
+	if err != nil {
+		cfmt.Fprintf(os.Stderr, "{{warning:}}::yellow failed to write gateway log: %v\n", err)
+	}
+}
+
+func appendStateLine(name string, line []byte) error {
+	path, err := state.Path(name)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = f.Write(append(line, '\n'))
+	return err
+}
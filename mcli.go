@@ -3,7 +3,7 @@ package main
 import (
 	"log/slog"
 	"os"
-	"time"
+	"unicode/utf8"
 
 	"github.com/alexflint/go-arg"
 	"github.com/brokenCursor/usb-modem-cli/config"
@@ -20,7 +20,7 @@ var (
 )
 
 func main() {
-	validate = validator.New(validator.WithRequiredStructEnabled())
+	validate = newValidator()
 	logger = logging.GetGeneralLogger()
 
 	if err := run(); err != nil {
@@ -28,6 +28,14 @@ func main() {
 	}
 }
 
+func newValidator() *validator.Validate {
+	v := validator.New(validator.WithRequiredStructEnabled())
+	v.RegisterValidation("utf8", func(fl validator.FieldLevel) bool {
+		return utf8.ValidString(fl.Field().String())
+	})
+	return v
+}
+
 func run() error {
 	parser := arg.MustParse(&args)
 
@@ -73,7 +81,7 @@ func run() error {
 		case "up":
 			err := cell.ConnectCell()
 			if err != nil {
-				return err
+				return explainSIMFailure(modem, err)
 			}
 		case "down":
 			err := cell.DisconnectCell()
@@ -97,35 +105,25 @@ func run() error {
 			case 3:
 				cfmt.Println("Status: {{up}}::green|bold")
 			}
+		case "config":
+			return runConnConfig(modem)
 		}
 	case args.SMS != nil:
-		sms, ok := modem.(drivers.ModemSMS)
-		if !ok {
-			return DriverSupportError{Driver: modem, Function: "SMS"}
-		}
-
-		switch {
-		case args.SMS.Send != nil:
-			err := validate.Struct(args.SMS.Send)
-			if err != nil {
-				logger.With("err", err.Error()).Debug("sms send validation error")
-				parser.FailSubcommand("Unknown values or action", "sms")
-			}
-
-			err = sms.SendSMS(args.SMS.Send.PhoneNumber, args.SMS.Send.Message)
-			if err != nil {
-				return err
-			}
-		case args.SMS.Read != nil:
-			messages, err := sms.ReadAllSMS()
-			if err != nil {
-				return err
-			}
-
-			for i := range messages {
-				cfmt.Printf("{{ID:}}::cyan %d\n{{Source:}}::green %s\n{{Time:}}::yellow %s\n{{Text:}}::#FA8100\n%s\n---\n", i, messages[i].Sender, messages[i].Time.Format(time.DateTime), messages[i].Message)
-			}
-		}
+		return runSMS(parser, modem)
+	case args.APN != nil:
+		return runAPN(parser, modem)
+	case args.SIM != nil:
+		return runSIM(parser, modem)
+	case args.Network != nil:
+		return runNetwork(parser, modem)
+	case args.Usage != nil:
+		return runUsage(parser, modem)
+	case args.Device != nil:
+		return runDevice(parser, modem)
+	case args.WiFi != nil:
+		return runWiFi(parser, modem)
+	case args.Daemon != nil:
+		return runDaemon(modem)
 	case parser.Subcommand() == nil:
 		parser.Fail("Missing or unknown command")
 	}
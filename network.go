@@ -0,0 +1,93 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/alexflint/go-arg"
+	"github.com/brokenCursor/usb-modem-cli/drivers"
+	"github.com/i582/cfmt/cmd/cfmt"
+)
+
+func runNetwork(parser *arg.Parser, modem drivers.BaseModem) error {
+	network, ok := modem.(drivers.ModemNetwork)
+	if !ok {
+		return DriverSupportError{Driver: modem, Function: "network management"}
+	}
+
+	switch {
+	case args.Network.Mode != nil:
+		if err := validate.Struct(args.Network.Mode); err != nil {
+			parser.FailSubcommand("Unknown network mode", "network", "mode")
+		}
+
+		if args.Network.Mode.Mode != "" {
+			return network.SetNetworkMode(drivers.NetworkMode(args.Network.Mode.Mode))
+		}
+
+		mode, err := network.GetNetworkMode()
+		if err != nil {
+			return err
+		}
+		cfmt.Printf("{{Mode:}}::cyan %s\n", mode)
+	case args.Network.Bands != nil:
+		if err := validate.Struct(args.Network.Bands); err != nil {
+			logger.With("err", err.Error()).Debug("network bands validation error")
+			parser.FailSubcommand("Use either --lock or --unlock with bands 1-64", "network", "bands")
+		}
+
+		switch {
+		case args.Network.Bands.Unlock:
+			return network.SetLTEBands(nil)
+		case len(args.Network.Bands.Lock) > 0:
+			return network.SetLTEBands(args.Network.Bands.Lock)
+		}
+
+		bands, err := network.GetLTEBands()
+		if err != nil {
+			return err
+		}
+
+		if len(bands) == 0 {
+			cfmt.Println("{{LTE bands:}}::cyan all")
+			return nil
+		}
+
+		names := make([]string, len(bands))
+		for i := range bands {
+			names[i] = "B" + strconv.Itoa(bands[i])
+		}
+		cfmt.Printf("{{LTE bands:}}::cyan %s\n", strings.Join(names, " "))
+	case args.Network.Scan != nil:
+		cfmt.Println("Scanning, this may take a few minutes...")
+		operators, err := network.ScanOperators()
+		if err != nil {
+			return err
+		}
+
+		for _, op := range operators {
+			switch op.State {
+			case drivers.OperatorCurrent:
+				cfmt.Printf("{{%s}}::cyan %s ({{%s}}::green|bold) {{%s}}::green\n", op.PLMN, op.Name, op.RAT, op.State)
+			case drivers.OperatorForbidden:
+				cfmt.Printf("{{%s}}::cyan %s ({{%s}}::green|bold) {{%s}}::red\n", op.PLMN, op.Name, op.RAT, op.State)
+			default:
+				cfmt.Printf("{{%s}}::cyan %s ({{%s}}::green|bold) %s\n", op.PLMN, op.Name, op.RAT, op.State)
+			}
+		}
+	case args.Network.Select != nil:
+		if err := validate.Struct(args.Network.Select); err != nil {
+			logger.With("err", err.Error()).Debug("network select validation error")
+			parser.FailSubcommand("Provide either an operator MCC+MNC or --auto", "network", "select")
+		}
+
+		if args.Network.Select.Auto {
+			return network.SelectOperatorAuto()
+		}
+		return network.SelectOperator(drivers.Operator{PLMN: args.Network.Select.PLMN, RAT: drivers.NetworkMode(args.Network.Select.RAT)})
+	default:
+		parser.FailSubcommand("Missing or unknown action", "network")
+	}
+
+	return nil
+}
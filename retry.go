@@ -0,0 +1,34 @@
+package main
+
+import (
+	"time"
+
+	"github.com/brokenCursor/usb-modem-cli/config"
+)
+
+type retryPolicy struct {
+	Backoff     time.Duration // Delay after the first failure, doubled after every next one
+	MaxBackoff  time.Duration
+	MaxAttempts int // 0 to retry forever
+}
+
+// Reads backoff, max_backoff and max_attempts from a config section
+func retryPolicyFromConfig(section string) retryPolicy {
+	c := config.Sub(section)
+	return retryPolicy{Backoff: c.GetDuration("backoff"), MaxBackoff: c.GetDuration("max_backoff"), MaxAttempts: c.GetInt("max_attempts")}
+}
+
+// Delay before the next attempt once something failed this many times
+func (p retryPolicy) delay(attempts int) time.Duration {
+	delay := p.Backoff
+	for i := 1; i < attempts && delay < p.MaxBackoff; i++ {
+		delay *= 2
+	}
+
+	return min(delay, p.MaxBackoff)
+}
+
+// Tells if something which failed this many times shouldn't be tried again
+func (p retryPolicy) exhausted(attempts int) bool {
+	return p.MaxAttempts > 0 && attempts >= p.MaxAttempts
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/alexflint/go-arg"
+	"github.com/brokenCursor/usb-modem-cli/drivers"
+	"github.com/i582/cfmt/cmd/cfmt"
+)
+
+func runSIM(parser *arg.Parser, modem drivers.BaseModem) error {
+	sim, ok := modem.(drivers.ModemSIM)
+	if !ok {
+		return DriverSupportError{Driver: modem, Function: "SIM management"}
+	}
+
+	switch {
+	case args.SIM.Status != nil:
+		status, err := sim.GetSIMStatus()
+		if err != nil {
+			return err
+		}
+
+		switch status.State {
+		case drivers.SIMReady:
+			cfmt.Println("State: {{ready}}::green|bold")
+		case drivers.SIMPINRequired, drivers.SIMPUKRequired:
+			cfmt.Printf("State: {{%s}}::yellow|bold\n", status.State)
+		default:
+			cfmt.Printf("State: {{%s}}::red|bold\n", status.State)
+		}
+
+		if status.PINLock {
+			cfmt.Println("{{PIN lock:}}::cyan enabled")
+		} else {
+			cfmt.Println("{{PIN lock:}}::cyan disabled")
+		}
+		if status.PINAttempts >= 0 {
+			cfmt.Printf("{{PIN attempts left:}}::cyan %d\n", status.PINAttempts)
+		}
+		if status.PUKAttempts >= 0 {
+			cfmt.Printf("{{PUK attempts left:}}::cyan %d\n", status.PUKAttempts)
+		}
+		if status.ICCID != "" {
+			cfmt.Printf("{{ICCID:}}::cyan %s\n", status.ICCID)
+		}
+	case args.SIM.Unlock != nil:
+		if err := validate.Struct(args.SIM.Unlock); err != nil {
+			logger.With("err", err.Error()).Debug("sim unlock validation error")
+			parser.FailSubcommand("Provide either --pin or --puk with --new-pin", "sim", "unlock")
+		}
+
+		if args.SIM.Unlock.PUK != "" {
+			return sim.EnterPUK(args.SIM.Unlock.PUK, args.SIM.Unlock.NewPIN)
+		}
+		return sim.EnterPIN(args.SIM.Unlock.PIN)
+	case args.SIM.PIN != nil:
+		if err := validate.Struct(args.SIM.PIN); err != nil {
+			logger.With("err", err.Error()).Debug("sim pin validation error")
+			parser.FailSubcommand("Unknown values or action", "sim", "pin")
+		}
+
+		switch args.SIM.PIN.Action {
+		case "enable":
+			return sim.SetPINLock(true, args.SIM.PIN.PIN)
+		case "disable":
+			return sim.SetPINLock(false, args.SIM.PIN.PIN)
+		case "change":
+			return sim.ChangePIN(args.SIM.PIN.PIN, args.SIM.PIN.NewPIN)
+		}
+	default:
+		parser.FailSubcommand("Missing or unknown action", "sim")
+	}
+
+	return nil
+}
+
+// Turns an opaque failure into a SIM error if the SIM is the reason
+func explainSIMFailure(modem drivers.BaseModem, cause error) error {
+	sim, ok := modem.(drivers.ModemSIM)
+	if !ok {
+		return cause
+	}
+
+	status, err := sim.GetSIMStatus()
+	if err != nil {
+		return cause
+	}
+
+	switch status.State {
+	case drivers.SIMPINRequired:
+		return fmt.Errorf("%w, unlock it with \"mcli sim unlock --pin ...\"", drivers.ErrSIMPINRequired)
+	case drivers.SIMPUKRequired:
+		return fmt.Errorf("%w, unlock it with \"mcli sim unlock --puk ... --new-pin ...\"", drivers.ErrSIMPUKRequired)
+	case drivers.SIMAbsent:
+		return drivers.ErrSIMAbsent
+	}
+
+	return cause
+}
@@ -0,0 +1,382 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/alexflint/go-arg"
+	"github.com/brokenCursor/usb-modem-cli/drivers"
+	"github.com/i582/cfmt/cmd/cfmt"
+)
+
+func runSMS(parser *arg.Parser, modem drivers.BaseModem) error {
+	sms, ok := modem.(drivers.ModemSMS)
+	if !ok {
+		return DriverSupportError{Driver: modem, Function: "SMS"}
+	}
+
+	switch {
+	case args.SMS.Send != nil:
+		err := validate.Struct(args.SMS.Send)
+		if err != nil {
+			logger.With("err", err.Error()).Debug("sms send validation error")
+			parser.FailSubcommand("Unknown values or action", "sms")
+		}
+
+		phone, err := drivers.NormalizePhone(args.SMS.Send.PhoneNumber)
+		if err != nil {
+			return err
+		}
+
+		message, err := readMessage(args.SMS.Send.Message, args.SMS.Send.File, drivers.SMSEncoding(args.SMS.Send.Encoding))
+		if err != nil {
+			return err
+		}
+
+		// Waiting implies asking for a report
+		report := args.SMS.Send.Report || args.SMS.Send.Wait > 0
+		if _, ok := sms.(drivers.ModemSMSReport); report && !ok {
+			return DriverSupportError{Driver: modem, Function: "delivery reports"}
+		}
+
+		opts := drivers.SMSSendOptions{Encoding: drivers.SMSEncoding(args.SMS.Send.Encoding), StatusReport: report}
+		if args.SMS.Send.Queue {
+			return queueSMS(phone, message, opts)
+		}
+
+		ref, err := sms.SendSMS(phone, message, opts)
+		if err != nil {
+			return err
+		}
+
+		if ref != "" {
+			cfmt.Printf("{{Reference:}}::cyan %s\n", ref)
+		}
+
+		if args.SMS.Send.Wait > 0 {
+			if ref == "" {
+				return ErrNoReference
+			}
+
+			delivery, err := waitForDelivery(sms.(drivers.ModemSMSReport), ref, args.SMS.Send.Wait)
+			if err != nil {
+				return err
+			}
+			printDelivery(delivery)
+		}
+	case args.SMS.Status != nil:
+		reports, ok := sms.(drivers.ModemSMSReport)
+		if !ok {
+			return DriverSupportError{Driver: modem, Function: "delivery reports"}
+		}
+
+		delivery, err := reports.GetSMSDelivery(args.SMS.Status.Ref)
+		if err != nil {
+			return err
+		}
+		printDelivery(delivery)
+	case args.SMS.Read != nil:
+		if err := validate.Struct(args.SMS.Read); err != nil {
+			parser.FailSubcommand("Unknown folder or invalid limit", "sms", "read")
+		}
+
+		query, err := smsQueryFromArgs(args.SMS.Read)
+		if err != nil {
+			parser.FailSubcommand(err.Error(), "sms", "read")
+		}
+
+		// Print messages as pages come in
+		it := drivers.ReassembleSMS(sms.IterSMS(query))
+		for it.Next() {
+			printSMS(it.SMS())
+		}
+
+		if err := it.Err(); err != nil {
+			return err
+		}
+	case args.SMS.Count != nil:
+		if err := validate.Struct(args.SMS.Count); err != nil {
+			parser.FailSubcommand("Unknown values", "sms", "count")
+		}
+
+		message, err := readMessage(args.SMS.Count.Message, args.SMS.Count.File, drivers.SMSEncoding(args.SMS.Count.Encoding))
+		if err != nil {
+			return err
+		}
+
+		info, err := drivers.CountSMS(message, drivers.SMSEncoding(args.SMS.Count.Encoding))
+		if err != nil {
+			return err
+		}
+
+		cfmt.Printf("{{Encoding:}}::cyan %s\n{{Length:}}::cyan %d\n{{Segments:}}::cyan %d\n{{Remaining:}}::cyan %d\n", info.Encoding, info.Length, info.Segments, info.Remaining)
+	case args.SMS.Bulk != nil:
+		if err := validate.Struct(args.SMS.Bulk); err != nil {
+			logger.With("err", err.Error()).Debug("sms bulk validation error")
+			parser.FailSubcommand("Unknown or invalid values", "sms", "bulk")
+		}
+
+		return runSMSBulk(sms, args.SMS.Bulk)
+	case args.SMS.Watch != nil:
+		if err := validate.Struct(args.SMS.Watch); err != nil {
+			parser.FailSubcommand("Invalid interval", "sms", "watch")
+		}
+
+		return runSMSWatch(sms, args.SMS.Watch)
+	case args.SMS.Sync != nil:
+		return runSMSSync(modem, sms)
+	case args.SMS.Search != nil:
+		if err := validate.Struct(args.SMS.Search); err != nil {
+			parser.FailSubcommand("Invalid limit", "sms", "search")
+		}
+
+		return runSMSSearch(parser, args.SMS.Search)
+	case args.SMS.Export != nil:
+		if err := validate.Struct(args.SMS.Export); err != nil {
+			parser.FailSubcommand("Unknown format or folder", "sms", "export")
+		}
+
+		return runSMSExport(sms, args.SMS.Export)
+	case args.SMS.Import != nil:
+		if err := validate.Struct(args.SMS.Import); err != nil {
+			logger.With("err", err.Error()).Debug("sms import validation error")
+			parser.FailSubcommand("Missing file or unknown format", "sms", "import")
+		}
+
+		return runSMSImport(modem, args.SMS.Import)
+	case args.SMS.Capacity != nil:
+		return runSMSCapacity(modem)
+	case args.SMS.Prune != nil:
+		if err := validate.Struct(args.SMS.Prune); err != nil {
+			parser.FailSubcommand("Threshold must be between 1 and 100", "sms", "prune")
+		}
+
+		return runSMSPrune(modem, sms, args.SMS.Prune)
+	case args.SMS.Schedule != nil:
+		return runSMSSchedule(parser, args.SMS.Schedule)
+	case args.SMS.Outbox != nil:
+		if purge := args.SMS.Outbox.Purge; purge != nil && validate.Struct(purge) != nil {
+			parser.FailSubcommand("Use either IDs or --all", "sms", "outbox", "purge")
+		}
+
+		return runSMSOutbox(parser, args.SMS.Outbox)
+	case args.SMS.Delete != nil:
+		if err := validate.Struct(args.SMS.Delete); err != nil {
+			logger.With("err", err.Error()).Debug("sms delete validation error")
+			parser.FailSubcommand("Use one of --id, --all-read and --all", "sms", "delete")
+		}
+
+		switch {
+		case args.SMS.Delete.All:
+			return sms.DeleteAllSMS()
+		case args.SMS.Delete.AllRead:
+			messages, err := sms.ReadAllSMS()
+			if err != nil {
+				return err
+			}
+
+			ids := []string{}
+			for i := range messages {
+				if messages[i].Read {
+					ids = append(ids, messages[i].ID)
+				}
+			}
+			return sms.DeleteSMS(ids...)
+		default:
+			ids, err := expandSMSIDs(sms, args.SMS.Delete.IDs)
+			if err != nil {
+				return err
+			}
+			return sms.DeleteSMS(ids...)
+		}
+	case args.SMS.MarkRead != nil:
+		if err := validate.Struct(args.SMS.MarkRead); err != nil {
+			logger.With("err", err.Error()).Debug("sms mark-read validation error")
+			parser.FailSubcommand("Use either --id or --all", "sms", "mark-read")
+		}
+
+		if !args.SMS.MarkRead.All {
+			ids, err := expandSMSIDs(sms, args.SMS.MarkRead.IDs)
+			if err != nil {
+				return err
+			}
+			return sms.MarkSMSRead(ids...)
+		}
+
+		messages, err := sms.ReadAllSMS()
+		if err != nil {
+			return err
+		}
+
+		ids := []string{}
+		for i := range messages {
+			if !messages[i].Read {
+				ids = append(ids, messages[i].ID)
+			}
+		}
+		return sms.MarkSMSRead(ids...)
+	default:
+		parser.FailSubcommand("Missing or unknown action", "sms")
+	}
+
+	return nil
+}
+
+// Longest message accepted from stdin or a file
+const maxMessageSize = 64 * 1024
+
+var ErrEmptyMessage = errors.New("message is empty")
+var ErrInvalidMessage = errors.New("message is not valid UTF-8 text")
+var ErrMessageTooLarge = fmt.Errorf("message is larger than %d bytes", maxMessageSize)
+
+// Returns the message given inline, on stdin ("-") or in a file and checks it can be encoded
+func readMessage(inline string, file string, encoding drivers.SMSEncoding) (string, error) {
+	message := inline
+
+	var source io.Reader
+	switch {
+	case file != "":
+		f, err := os.Open(file)
+		if err != nil {
+			return "", err
+		}
+		defer f.Close()
+		source = f
+	case inline == "-":
+		source = os.Stdin
+	}
+
+	if source != nil {
+		raw, err := readMessageBytes(source)
+		if err != nil {
+			return "", err
+		}
+
+		// Drop the newline most tools end their output with
+		message = strings.TrimRight(string(raw), "\r\n")
+	}
+
+	if err := validate.Var(message, "required"); err != nil {
+		return "", ErrEmptyMessage
+	}
+	if err := validate.Var(message, "utf8"); err != nil {
+		return "", ErrInvalidMessage
+	}
+
+	if _, err := drivers.CountSMS(message, encoding); err != nil {
+		return "", err
+	}
+
+	return message, nil
+}
+
+// Adds the other parts of joined messages, which are shown under the first part's ID
+func expandSMSIDs(sms drivers.ModemSMS, ids []string) ([]string, error) {
+	messages, err := drivers.CollectSMS(drivers.ReassembleSMS(sms.IterSMS(drivers.SMSQuery{Folder: drivers.SMSFolderAll})))
+	if err != nil {
+		return nil, err
+	}
+
+	expanded := []string{}
+	for _, id := range ids {
+		parts := []string{id}
+		if i := slices.IndexFunc(messages, func(m drivers.SMS) bool { return m.ID == id }); i >= 0 {
+			parts = messages[i].IDs()
+		}
+
+		for _, part := range parts {
+			if !slices.Contains(expanded, part) {
+				expanded = append(expanded, part)
+			}
+		}
+	}
+
+	return expanded, nil
+}
+
+// Reads up to maxMessageSize bytes, failing instead of cutting longer input
+func readMessageBytes(r io.Reader) ([]byte, error) {
+	raw, err := io.ReadAll(io.LimitReader(r, maxMessageSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read message: %w", err)
+	}
+	if len(raw) > maxMessageSize {
+		return nil, ErrMessageTooLarge
+	}
+
+	return raw, nil
+}
+
+// Delivery reports usually take a few seconds
+const deliveryPollInterval = 5 * time.Second
+
+var ErrNoReference = errors.New("sent message couldn't be found on the modem to track it")
+
+// Polls the delivery state until it's final or the timeout runs out
+func waitForDelivery(reports drivers.ModemSMSReport, ref string, timeout time.Duration) (*drivers.SMSDelivery, error) {
+	deadline := time.Now().Add(timeout)
+
+	for {
+		delivery, err := reports.GetSMSDelivery(ref)
+		if err != nil {
+			return nil, err
+		}
+
+		if delivery.State.Final() || time.Now().Add(deliveryPollInterval).After(deadline) {
+			return delivery, nil
+		}
+		time.Sleep(deliveryPollInterval)
+	}
+}
+
+func printDelivery(delivery *drivers.SMSDelivery) {
+	switch delivery.State {
+	case drivers.SMSDelivered:
+		cfmt.Printf("Status: {{%s}}::green|bold at %s\n", delivery.State, delivery.Time.Format(time.DateTime))
+	case drivers.SMSPending, drivers.SMSUnknown:
+		cfmt.Printf("Status: {{%s}}::yellow|bold\n", delivery.State)
+	default:
+		cfmt.Printf("Status: {{%s}}::red|bold at %s\n", delivery.State, delivery.Time.Format(time.DateTime))
+	}
+}
+
+func smsQueryFromArgs(a *SMSReadArgs) (query drivers.SMSQuery, err error) {
+	query = drivers.SMSQuery{
+		Folder:     drivers.SMSFolder(a.Folder),
+		UnreadOnly: a.Unread,
+		Sender:     drivers.DisplayPhone(a.From),
+		Limit:      a.Limit,
+	}
+
+	if a.Since != "" {
+		if query.Since, err = parseTime(a.Since); err != nil {
+			return
+		}
+	}
+	if a.Until != "" {
+		if query.Until, err = parseTime(a.Until); err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+func printSMS(message *drivers.SMS) {
+	if message.Read {
+		cfmt.Printf("{{ID:}}::cyan %s\n", message.ID)
+	} else {
+		cfmt.Printf("{{ID:}}::cyan %s {{(unread)}}::yellow|bold\n", message.ID)
+	}
+	if message.Folder == drivers.SMSFolderInbox {
+		cfmt.Printf("{{Source:}}::green %s\n", drivers.DisplayPhone(message.Sender))
+	} else {
+		cfmt.Printf("{{Recipient:}}::green %s\n", drivers.DisplayPhone(message.Sender))
+	}
+	cfmt.Printf("{{Time:}}::yellow %s\n{{Text:}}::#FA8100\n%s\n---\n", message.Time.Format(time.DateTime), message.Message)
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"time"
+
+	"github.com/alexflint/go-arg"
+	"github.com/brokenCursor/usb-modem-cli/archive"
+	"github.com/brokenCursor/usb-modem-cli/config"
+	"github.com/brokenCursor/usb-modem-cli/drivers"
+	"github.com/brokenCursor/usb-modem-cli/state"
+	"github.com/i582/cfmt/cmd/cfmt"
+)
+
+func openArchive() (*archive.Archive, error) {
+	path, err := state.Path("archive.db")
+	if err != nil {
+		return nil, err
+	}
+
+	return archive.Open(path)
+}
+
+// Identifies the modem messages are read from
+func modemKey(modem drivers.BaseModem) string {
+	return modem.GetModel() + "@" + config.Sub("modem").GetString("host")
+}
+
+// Returns the ICCID of the SIM if the driver can tell, archive works without it
+func simICCID(modem drivers.BaseModem) string {
+	sim, ok := modem.(drivers.ModemSIM)
+	if !ok {
+		return ""
+	}
+
+	status, err := sim.GetSIMStatus()
+	if err != nil {
+		logger.With("err", err.Error()).Debug("failed to get SIM ICCID for archive")
+		return ""
+	}
+	return status.ICCID
+}
+
+// Copies every message on the modem and its SIM into the archive
+func archiveSMS(modem drivers.BaseModem, sms drivers.ModemSMS, store *archive.Archive) (added int, total int, err error) {
+	iccid := simICCID(modem)
+	for _, storage := range smsStorages(sms) {
+		it := drivers.ReassembleSMS(sms.IterSMS(drivers.SMSQuery{Folder: drivers.SMSFolderAll, Storage: storage}))
+		for it.Next() {
+			total++
+
+			ok, err := store.Add(archive.Record{SMS: *it.SMS(), Modem: modemKey(modem), ICCID: iccid})
+			if err != nil {
+				return added, total, err
+			}
+			if ok {
+				added++
+			}
+		}
+		if err := it.Err(); err != nil {
+			return added, total, err
+		}
+	}
+
+	return added, total, nil
+}
+
+func runSMSSync(modem drivers.BaseModem, sms drivers.ModemSMS) error {
+	store, err := openArchive()
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	added, total, err := archiveSMS(modem, sms, store)
+	if err != nil {
+		return err
+	}
+
+	cfmt.Printf("{{Archived:}}::cyan %d new of %d messages\n", added, total)
+	return nil
+}
+
+func runSMSSearch(parser *arg.Parser, a *SMSSearchArgs) error {
+	query := archive.Query{Text: a.Text, Sender: drivers.DisplayPhone(a.From), Limit: a.Limit}
+
+	var err error
+	if a.Since != "" {
+		if query.Since, err = parseTime(a.Since); err != nil {
+			parser.FailSubcommand(err.Error(), "sms", "search")
+		}
+	}
+	if a.Until != "" {
+		if query.Until, err = parseTime(a.Until); err != nil {
+			parser.FailSubcommand(err.Error(), "sms", "search")
+		}
+	}
+
+	store, err := openArchive()
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	records, err := store.Search(query)
+	if err != nil {
+		return err
+	}
+
+	for i := range records {
+		cfmt.Printf("{{Modem:}}::cyan %s", records[i].Modem)
+		if records[i].ICCID != "" {
+			cfmt.Printf(" {{(SIM %s)}}::gray", records[i].ICCID)
+		}
+		cfmt.Printf("\n{{Archived:}}::cyan %s\n", records[i].Archived.Format(time.DateTime))
+		printSMS(&records[i].SMS)
+	}
+
+	return nil
+}
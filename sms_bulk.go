@@ -0,0 +1,231 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/brokenCursor/usb-modem-cli/drivers"
+	"github.com/i582/cfmt/cmd/cfmt"
+)
+
+// Bulk report statuses
+const (
+	bulkSent   = "sent"
+	bulkFailed = "failed"
+)
+
+var bulkReportHeader = []string{"row", "phone", "hash", "status", "error", "time"}
+
+type bulkMessage struct {
+	row     int // Line in the CSV file
+	phone   string
+	message string
+}
+
+// Identifies a message across runs, so rows can be edited, added or moved.
+// The hash is of the rendered text
+func (m *bulkMessage) key() string {
+	return m.phone + "/" + m.hash()
+}
+
+func (m *bulkMessage) hash() string {
+	sum := sha256.Sum256([]byte(m.message))
+	return hex.EncodeToString(sum[:8])
+}
+
+func runSMSBulk(sms drivers.ModemSMS, a *SMSBulkArgs) error {
+	tmpl, err := template.New("sms").Option("missingkey=error").Parse(a.Template)
+	if err != nil {
+		return fmt.Errorf("invalid template: %w", err)
+	}
+
+	messages, err := renderBulk(a, tmpl)
+	if err != nil {
+		return err
+	}
+
+	done, err := readBulkReport(a.Report)
+	if err != nil {
+		return err
+	}
+
+	if a.DryRun {
+		for _, message := range messages {
+			if done[message.key()] > 0 {
+				done[message.key()]--
+				cfmt.Printf("{{%d}}::cyan {{%s}}::green {{(already sent)}}::yellow\n", message.row, message.phone)
+				continue
+			}
+			cfmt.Printf("{{%d}}::cyan {{%s}}::green %s\n", message.row, message.phone, message.message)
+		}
+		return nil
+	}
+
+	report, err := openBulkReport(a.Report)
+	if err != nil {
+		return err
+	}
+	defer report.Close()
+	writer := csv.NewWriter(report)
+
+	interval := time.Duration(float64(time.Minute) / a.Rate)
+	opts := drivers.SMSSendOptions{Encoding: drivers.SMSEncoding(a.Encoding)}
+
+	sent, failed, skipped := 0, 0, 0
+	var last time.Time
+	for _, message := range messages {
+		if done[message.key()] > 0 {
+			logger.With("row", message.row).Debug("already sent, skipping")
+			done[message.key()]--
+			skipped++
+			continue
+		}
+
+		// One message at a time, no faster than the rate
+		time.Sleep(time.Until(last.Add(interval)))
+		last = time.Now()
+
+		status, errText := bulkSent, ""
+		if _, err := sms.SendSMS(message.phone, message.message, opts); err != nil {
+			status, errText = bulkFailed, err.Error()
+			failed++
+			cfmt.Printf("{{%d}}::cyan {{%s}}::green {{failed:}}::red %s\n", message.row, message.phone, errText)
+		} else {
+			sent++
+			cfmt.Printf("{{%d}}::cyan {{%s}}::green {{sent}}::green|bold\n", message.row, message.phone)
+		}
+
+		// Flush every row so an interrupted run can be resumed
+		writer.Write([]string{strconv.Itoa(message.row), message.phone, message.hash(), status, errText, last.Format(time.RFC3339)})
+		writer.Flush()
+		if err := writer.Error(); err != nil {
+			return fmt.Errorf("failed to write report: %w", err)
+		}
+	}
+
+	cfmt.Printf("{{Sent:}}::green %d {{Failed:}}::red %d {{Skipped:}}::yellow %d\n", sent, failed, skipped)
+	return nil
+}
+
+// Reads the CSV and renders a message for every row, failing on any invalid row
+func renderBulk(a *SMSBulkArgs, tmpl *template.Template) ([]bulkMessage, error) {
+	file, err := os.Open(a.CSV)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CSV header: %w", err)
+	}
+
+	phoneColumn := -1
+	for i := range header {
+		header[i] = strings.TrimSpace(header[i])
+		if header[i] == a.PhoneColumn {
+			phoneColumn = i
+		}
+	}
+	if phoneColumn < 0 {
+		return nil, fmt.Errorf("CSV has no %q column", a.PhoneColumn)
+	}
+
+	messages := []bulkMessage{}
+	problems := []string{}
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CSV: %w", err)
+		}
+		row, _ := reader.FieldPos(0)
+
+		fields := map[string]string{}
+		for i := range header {
+			fields[header[i]] = strings.TrimSpace(record[i])
+		}
+
+		phone, err := drivers.NormalizePhone(fields[a.PhoneColumn])
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("row %d: %v", row, err))
+			continue
+		}
+
+		text := new(strings.Builder)
+		if err := tmpl.Execute(text, fields); err != nil {
+			problems = append(problems, fmt.Sprintf("row %d: %v", row, err))
+			continue
+		}
+
+		if _, err := drivers.CountSMS(text.String(), drivers.SMSEncoding(a.Encoding)); err != nil {
+			problems = append(problems, fmt.Sprintf("row %d: %v", row, err))
+			continue
+		}
+
+		messages = append(messages, bulkMessage{row: row, phone: phone, message: text.String()})
+	}
+
+	// Nothing is sent unless every row is valid
+	if len(problems) > 0 {
+		return nil, fmt.Errorf("%d invalid rows:\n%s", len(problems), strings.Join(problems, "\n"))
+	}
+
+	return messages, nil
+}
+
+// Returns how many times every message has already been sent, by key
+func readBulkReport(path string) (map[string]int, error) {
+	done := map[string]int{}
+
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return done, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	records, err := csv.NewReader(file).ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read report: %w", err)
+	}
+
+	for _, record := range records {
+		if len(record) < len(bulkReportHeader) || record[3] != bulkSent {
+			continue
+		}
+		done[record[1]+"/"+record[2]]++
+	}
+
+	return done, nil
+}
+
+func openBulkReport(path string) (*os.File, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, err
+	}
+
+	// New report, write the header
+	if info, err := file.Stat(); err == nil && info.Size() == 0 {
+		writer := csv.NewWriter(file)
+		writer.Write(bulkReportHeader)
+		writer.Flush()
+	}
+
+	return file, nil
+}
@@ -0,0 +1,408 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/brokenCursor/usb-modem-cli/drivers"
+	"github.com/i582/cfmt/cmd/cfmt"
+)
+
+type smsFormat struct {
+	write func(w io.Writer, messages []drivers.SMS) error
+	read  func(r io.Reader) ([]drivers.SMS, error)
+}
+
+var smsFormats = map[string]smsFormat{
+	"json": {writeSMSJSON, readSMSJSON},
+	"csv":  {writeSMSCSV, readSMSCSV},
+	"mbox": {writeSMSMbox, readSMSMbox},
+	"xml":  {writeSMSXML, readSMSXML},
+}
+
+var ErrUnknownFormat = errors.New("unknown format, use --format")
+
+func runSMSExport(sms drivers.ModemSMS, a *SMSExportArgs) error {
+	messages := []drivers.SMS{}
+	for _, storage := range smsStorages(sms) {
+		stored, err := drivers.CollectSMS(drivers.ReassembleSMS(sms.IterSMS(drivers.SMSQuery{Folder: drivers.SMSFolder(a.Folder), Storage: storage})))
+		if err != nil {
+			return err
+		}
+		messages = append(messages, stored...)
+	}
+
+	// Newest first, as if read from a single storage
+	slices.SortStableFunc(messages, func(a, b drivers.SMS) int { return b.Time.Compare(a.Time) })
+
+	if a.Output == "" {
+		return smsFormats[a.Format].write(os.Stdout, messages)
+	}
+
+	var buf bytes.Buffer
+	if err := smsFormats[a.Format].write(&buf, messages); err != nil {
+		return err
+	}
+	return os.WriteFile(a.Output, buf.Bytes(), 0o600)
+}
+
+func runSMSImport(modem drivers.BaseModem, a *SMSImportArgs) error {
+	// Guess the format from the extension
+	format := a.Format
+	if format == "" {
+		format = strings.TrimPrefix(strings.ToLower(filepath.Ext(a.File)), ".")
+	}
+	if _, ok := smsFormats[format]; !ok {
+		return ErrUnknownFormat
+	}
+
+	f, err := os.Open(a.File)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	messages, err := smsFormats[format].read(f)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", a.File, err)
+	}
+
+	if a.DryRun {
+		for i := range messages {
+			printSMS(&messages[i])
+		}
+		return nil
+	}
+
+	store, ok := modem.(drivers.ModemSMSStore)
+	if !ok {
+		return DriverSupportError{Driver: modem, Function: "storing SMS"}
+	}
+
+	// Importing the same file twice mustn't store everything again
+	seen := map[string]bool{}
+	for _, storage := range smsStorages(store) {
+		onModem, err := store.ReadSMS(drivers.SMSQuery{Folder: drivers.SMSFolderAll, Storage: storage})
+		if err != nil {
+			return err
+		}
+		for i := range onModem {
+			seen[smsImportKey(&onModem[i])] = true
+		}
+	}
+
+	stored, skipped := 0, 0
+	for i := range messages {
+		key := smsImportKey(&messages[i])
+		if seen[key] {
+			skipped++
+			continue
+		}
+
+		if err := store.StoreSMS(messages[i]); err != nil {
+			return fmt.Errorf("stored %d of %d messages: %w", stored, len(messages)-skipped, err)
+		}
+		seen[key] = true
+		stored++
+	}
+
+	cfmt.Printf("{{Imported:}}::cyan %d messages, %d already on the modem\n", stored, skipped)
+	return nil
+}
+
+// Identifies a message across export and import. Folder and read state aren't
+// part of it, since drivers may not keep them
+func smsImportKey(message *drivers.SMS) string {
+	return fmt.Sprintf("%s\x00%d\x00%s", drivers.DisplayPhone(message.Sender), message.Time.Unix(), message.Message)
+}
+
+func writeSMSJSON(w io.Writer, messages []drivers.SMS) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(messages)
+}
+
+func readSMSJSON(r io.Reader) ([]drivers.SMS, error) {
+	messages := []drivers.SMS{}
+	return messages, json.NewDecoder(r).Decode(&messages)
+}
+
+var smsCSVHeader = []string{"id", "folder", "time", "sender", "read", "message"}
+
+func writeSMSCSV(w io.Writer, messages []drivers.SMS) error {
+	out := csv.NewWriter(w)
+	out.Write(smsCSVHeader)
+	for i := range messages {
+		out.Write([]string{
+			messages[i].ID,
+			string(messages[i].Folder),
+			messages[i].Time.Format(time.RFC3339),
+			messages[i].Sender,
+			strconv.FormatBool(messages[i].Read),
+			messages[i].Message,
+		})
+	}
+
+	out.Flush()
+	return out.Error()
+}
+
+func readSMSCSV(r io.Reader) ([]drivers.SMS, error) {
+	in := csv.NewReader(r)
+	in.FieldsPerRecord = len(smsCSVHeader)
+
+	records, err := in.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, nil
+	}
+
+	messages := make([]drivers.SMS, 0, len(records)-1)
+	for line, record := range records[1:] {
+		t, err := time.Parse(time.RFC3339, record[2])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line+2, err)
+		}
+
+		messages = append(messages, drivers.SMS{
+			ID:      record[0],
+			Folder:  drivers.SMSFolder(record[1]),
+			Time:    t,
+			Sender:  record[3],
+			Read:    record[4] == "true",
+			Message: record[5],
+		})
+	}
+
+	return messages, nil
+}
+
+// Lines which would start a new message have to be quoted, mboxrd style
+var (
+	mboxFromLine   = regexp.MustCompile(`^>*From `)
+	mboxQuotedLine = regexp.MustCompile(`^>+From `)
+)
+
+func writeSMSMbox(w io.Writer, messages []drivers.SMS) error {
+	out := bufio.NewWriter(w)
+
+	for i := range messages {
+		m := &messages[i]
+
+		subject := "SMS from " + m.Sender
+		if m.Folder != drivers.SMSFolderInbox {
+			subject = "SMS to " + m.Sender
+		}
+
+		fmt.Fprintf(out, "From %s %s\n", m.Sender, m.Time.UTC().Format(time.ANSIC))
+		fmt.Fprintf(out, "Date: %s\n", m.Time.Format(time.RFC1123Z))
+		fmt.Fprintf(out, "Subject: %s\n", subject)
+		fmt.Fprintf(out, "X-SMS-ID: %s\n", m.ID)
+		fmt.Fprintf(out, "X-SMS-Folder: %s\n", m.Folder)
+		fmt.Fprintf(out, "X-SMS-Number: %s\n", m.Sender)
+		fmt.Fprintf(out, "X-SMS-Read: %t\n", m.Read)
+		fmt.Fprintf(out, "Content-Type: text/plain; charset=utf-8\nContent-Transfer-Encoding: 8bit\n\n")
+
+		for _, line := range strings.Split(m.Message, "\n") {
+			if mboxFromLine.MatchString(line) {
+				out.WriteString(">")
+			}
+			out.WriteString(line + "\n")
+		}
+		out.WriteString("\n")
+	}
+
+	return out.Flush()
+}
+
+func readSMSMbox(r io.Reader) ([]drivers.SMS, error) {
+	messages := []drivers.SMS{}
+
+	var chunk []string
+	parse := func() error {
+		if len(chunk) == 0 {
+			return nil
+		}
+
+		// Drop the separator line written after every message
+		if chunk[len(chunk)-1] == "" {
+			chunk = chunk[:len(chunk)-1]
+		}
+
+		msg, err := mail.ReadMessage(strings.NewReader(strings.Join(chunk, "\n")))
+		if err != nil {
+			return err
+		}
+
+		t, err := mail.ParseDate(msg.Header.Get("Date"))
+		if err != nil {
+			return err
+		}
+
+		body, err := io.ReadAll(msg.Body)
+		if err != nil {
+			return err
+		}
+		lines := strings.Split(strings.TrimSuffix(string(body), "\n"), "\n")
+		for i := range lines {
+			if mboxQuotedLine.MatchString(lines[i]) {
+				lines[i] = lines[i][1:]
+			}
+		}
+
+		messages = append(messages, drivers.SMS{
+			ID:      msg.Header.Get("X-SMS-ID"),
+			Folder:  drivers.SMSFolder(msg.Header.Get("X-SMS-Folder")),
+			Time:    t,
+			Sender:  msg.Header.Get("X-SMS-Number"),
+			Read:    msg.Header.Get("X-SMS-Read") == "true",
+			Message: strings.Join(lines, "\n"),
+		})
+		return nil
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, maxMessageSize*2)
+	for scanner.Scan() {
+		if strings.HasPrefix(scanner.Text(), "From ") {
+			if err := parse(); err != nil {
+				return nil, err
+			}
+			chunk = chunk[:0]
+			continue
+		}
+		chunk = append(chunk, scanner.Text())
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return messages, parse()
+}
+
+type (
+	// Layout of SMS Backup & Restore for Android
+	smsBackup struct {
+		XMLName xml.Name         `xml:"smses"`
+		Count   int              `xml:"count,attr"`
+		SMS     []smsBackupEntry `xml:"sms"`
+	}
+
+	smsBackupEntry struct {
+		Protocol      string `xml:"protocol,attr"`
+		Address       string `xml:"address,attr"`
+		Date          int64  `xml:"date,attr"` // Unix milliseconds
+		Type          int    `xml:"type,attr"`
+		Subject       string `xml:"subject,attr"`
+		Body          string `xml:"body,attr"`
+		TOA           string `xml:"toa,attr"`
+		SCTOA         string `xml:"sc_toa,attr"`
+		ServiceCenter string `xml:"service_center,attr"`
+		Read          int    `xml:"read,attr"`
+		Status        int    `xml:"status,attr"`
+		Locked        int    `xml:"locked,attr"`
+		DateSent      int64  `xml:"date_sent,attr"`
+		ReadableDate  string `xml:"readable_date,attr"`
+		ContactName   string `xml:"contact_name,attr"`
+	}
+)
+
+// Message types used by Android
+var smsBackupTypes = map[drivers.SMSFolder]int{
+	drivers.SMSFolderInbox:  1,
+	drivers.SMSFolderSent:   2,
+	drivers.SMSFolderDrafts: 3,
+}
+
+func writeSMSXML(w io.Writer, messages []drivers.SMS) error {
+	backup := smsBackup{Count: len(messages), SMS: make([]smsBackupEntry, 0, len(messages))}
+
+	for i := range messages {
+		m := &messages[i]
+
+		entry := smsBackupEntry{
+			Protocol:      "0",
+			Address:       m.Sender,
+			Date:          m.Time.UnixMilli(),
+			Type:          smsBackupTypes[m.Folder],
+			Subject:       "null",
+			Body:          m.Message,
+			TOA:           "null",
+			SCTOA:         "null",
+			ServiceCenter: "null",
+			Status:        -1,
+			ReadableDate:  m.Time.Format(time.RFC3339),
+			ContactName:   "(Unknown)",
+		}
+		if m.Read {
+			entry.Read = 1
+		}
+		if m.Folder == drivers.SMSFolderInbox {
+			entry.DateSent = entry.Date
+		}
+
+		backup.SMS = append(backup.SMS, entry)
+	}
+
+	if _, err := io.WriteString(w, "<?xml version='1.0' encoding='UTF-8' standalone='yes' ?>\n"); err != nil {
+		return err
+	}
+
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(&backup); err != nil {
+		return err
+	}
+
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+func readSMSXML(r io.Reader) ([]drivers.SMS, error) {
+	backup := smsBackup{}
+	if err := xml.NewDecoder(r).Decode(&backup); err != nil {
+		return nil, err
+	}
+
+	messages := make([]drivers.SMS, 0, len(backup.SMS))
+	for _, entry := range backup.SMS {
+		// Readable date keeps the time zone, when it was written by us
+		t, err := time.Parse(time.RFC3339, entry.ReadableDate)
+		if err != nil {
+			t = time.UnixMilli(entry.Date)
+		}
+
+		folder := drivers.SMSFolderInbox
+		for f, typ := range smsBackupTypes {
+			if typ == entry.Type {
+				folder = f
+			}
+		}
+
+		messages = append(messages, drivers.SMS{
+			Folder:  folder,
+			Time:    t,
+			Sender:  entry.Address,
+			Read:    entry.Read == 1,
+			Message: entry.Body,
+		})
+	}
+
+	return messages, nil
+}
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/alexflint/go-arg"
+	"github.com/brokenCursor/usb-modem-cli/drivers"
+	"github.com/brokenCursor/usb-modem-cli/state"
+	"github.com/i582/cfmt/cmd/cfmt"
+)
+
+const outboxFile = "sms-outbox.json"
+
+type (
+	smsOutbox struct {
+		LastID   int         `json:"last_id"`
+		Messages []outboxSMS `json:"messages"`
+	}
+
+	// Delivered messages are removed from the outbox
+	outboxSMS struct {
+		ID           string    `json:"id"`
+		Phone        string    `json:"phone"`
+		Message      string    `json:"message"`
+		Encoding     string    `json:"encoding"`
+		StatusReport bool      `json:"status_report"`
+		Queued       time.Time `json:"queued"`
+		Attempts     int       `json:"attempts"`
+		NextAttempt  time.Time `json:"next_attempt"`
+		LastError    string    `json:"last_error,omitempty"`
+		Dead         bool      `json:"dead"` // Failed for good, only retried by hand
+	}
+)
+
+var ErrOutboxNotFound = errors.New("queued message not found")
+
+// Puts a message into the outbox for the daemon to deliver
+func queueSMS(phone string, message string, opts drivers.SMSSendOptions) error {
+	id, err := enqueueSMS(phone, message, opts)
+	if err != nil {
+		return err
+	}
+
+	cfmt.Printf("{{Queued:}}::cyan %s\n", id)
+	return nil
+}
+
+// Same as queueSMS, returning the ID instead of printing it
+func enqueueSMS(phone string, message string, opts drivers.SMSSendOptions) (string, error) {
+	queued := outboxSMS{Phone: phone, Message: message, Encoding: string(opts.Encoding), StatusReport: opts.StatusReport, Queued: time.Now()}
+	queued.NextAttempt = queued.Queued
+
+	outbox := smsOutbox{}
+	err := state.Update(outboxFile, &outbox, func() error {
+		outbox.LastID++
+		queued.ID = strconv.Itoa(outbox.LastID)
+		outbox.Messages = append(outbox.Messages, queued)
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return queued.ID, nil
+}
+
+func runSMSOutbox(parser *arg.Parser, a *SMSOutboxArgs) error {
+	switch {
+	case a.List != nil:
+		return listOutbox()
+	case a.Retry != nil:
+		return retryOutbox(a.Retry.IDs)
+	case a.Purge != nil:
+		return purgeOutbox(a.Purge.IDs, a.Purge.All)
+	default:
+		parser.FailSubcommand("Missing or unknown action", "sms", "outbox")
+	}
+
+	return nil
+}
+
+func listOutbox() error {
+	outbox := smsOutbox{}
+	if err := state.Load(outboxFile, &outbox); err != nil {
+		return err
+	}
+
+	for _, queued := range outbox.Messages {
+		cfmt.Printf("{{ID:}}::cyan %s\n{{Recipient:}}::green %s\n{{Queued:}}::yellow %s\n", queued.ID, queued.Phone, queued.Queued.Format(time.DateTime))
+
+		switch {
+		case queued.Dead:
+			cfmt.Printf("Status: {{failed}}::red|bold after %d attempts\n", queued.Attempts)
+		case queued.Attempts > 0:
+			cfmt.Printf("Status: {{retrying}}::yellow|bold at %s, %d attempts so far\n", queued.NextAttempt.Format(time.DateTime), queued.Attempts)
+		default:
+			cfmt.Println("Status: {{queued}}::yellow|bold")
+		}
+		if queued.LastError != "" {
+			cfmt.Printf("{{Error:}}::red %s\n", queued.LastError)
+		}
+
+		cfmt.Printf("{{Text:}}::#FA8100\n%s\n---\n", queued.Message)
+	}
+
+	return nil
+}
+
+// Puts messages back into the queue, all failed ones if no IDs are given
+func retryOutbox(ids []string) error {
+	outbox := smsOutbox{}
+	return state.Update(outboxFile, &outbox, func() error {
+		if err := checkOutboxIDs(&outbox, ids); err != nil {
+			return err
+		}
+
+		for i := range outbox.Messages {
+			queued := &outbox.Messages[i]
+			if (len(ids) == 0 && queued.Dead) || slices.Contains(ids, queued.ID) {
+				queued.Dead = false
+				queued.Attempts = 0
+				queued.NextAttempt = time.Now()
+			}
+		}
+		return nil
+	})
+}
+
+// Removes messages from the outbox, all failed ones if no IDs are given
+func purgeOutbox(ids []string, all bool) error {
+	outbox := smsOutbox{}
+	return state.Update(outboxFile, &outbox, func() error {
+		if err := checkOutboxIDs(&outbox, ids); err != nil {
+			return err
+		}
+
+		outbox.Messages = slices.DeleteFunc(outbox.Messages, func(queued outboxSMS) bool {
+			return all || (len(ids) == 0 && queued.Dead) || slices.Contains(ids, queued.ID)
+		})
+		return nil
+	})
+}
+
+func checkOutboxIDs(outbox *smsOutbox, ids []string) error {
+	for _, id := range ids {
+		if !slices.ContainsFunc(outbox.Messages, func(queued outboxSMS) bool { return queued.ID == id }) {
+			return fmt.Errorf("%w: %s", ErrOutboxNotFound, id)
+		}
+	}
+
+	return nil
+}
+
+// Tries to send queued messages which are due, oldest first. Messages are
+// retried on transient errors and dead-lettered on permanent ones or once
+// they run out of attempts
+func deliverOutbox(sms drivers.ModemSMS, policy retryPolicy) error {
+	// Only lock the outbox when there is something to send
+	outbox := smsOutbox{}
+	if err := state.Load(outboxFile, &outbox); err != nil {
+		return err
+	}
+	now := time.Now()
+	if !slices.ContainsFunc(outbox.Messages, func(queued outboxSMS) bool { return queued.due(now) }) {
+		return nil
+	}
+
+	// Claim due messages by moving their next attempt on, so they aren't sent twice
+	due := []outboxSMS{}
+	err := state.Update(outboxFile, &outbox, func() error {
+		for i := range outbox.Messages {
+			if queued := &outbox.Messages[i]; queued.due(now) {
+				queued.Attempts++
+				queued.NextAttempt = now.Add(policy.delay(queued.Attempts))
+				due = append(due, *queued)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, queued := range due {
+		opts := drivers.SMSSendOptions{Encoding: drivers.SMSEncoding(queued.Encoding), StatusReport: queued.StatusReport}
+		ref, sendErr := sms.SendSMS(queued.Phone, queued.Message, opts)
+		dead := sendErr != nil && (!drivers.IsTransient(sendErr) || policy.exhausted(queued.Attempts))
+
+		switch {
+		case sendErr == nil && ref != "":
+			cfmt.Printf("{{Sent:}}::cyan queued message %s to %s, reference %s\n", queued.ID, queued.Phone, ref)
+		case sendErr == nil:
+			cfmt.Printf("{{Sent:}}::cyan queued message %s to %s\n", queued.ID, queued.Phone)
+		case dead:
+			cfmt.Fprintf(os.Stderr, "{{warning:}}::yellow queued message %s to %s failed for good: %v\n", queued.ID, queued.Phone, sendErr)
+		default:
+			cfmt.Fprintf(os.Stderr, "{{warning:}}::yellow queued message %s to %s: %v, retrying at %s\n", queued.ID, queued.Phone, sendErr, queued.NextAttempt.Format(time.DateTime))
+		}
+
+		err := state.Update(outboxFile, &outbox, func() error {
+			i := slices.IndexFunc(outbox.Messages, func(m outboxSMS) bool { return m.ID == queued.ID })
+			switch {
+			case i < 0:
+				// Purged while sending
+			case sendErr == nil:
+				outbox.Messages = slices.Delete(outbox.Messages, i, i+1)
+			default:
+				outbox.Messages[i].LastError = sendErr.Error()
+				outbox.Messages[i].Dead = dead
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		if sendErr == nil || dead {
+			if err := recordScheduleResult(queued.ID, sendErr); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (m *outboxSMS) due(now time.Time) bool {
+	return !m.Dead && !m.NextAttempt.After(now)
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"slices"
+
+	"github.com/brokenCursor/usb-modem-cli/archive"
+	"github.com/brokenCursor/usb-modem-cli/config"
+	"github.com/brokenCursor/usb-modem-cli/drivers"
+	"github.com/i582/cfmt/cmd/cfmt"
+)
+
+type prunePolicy struct {
+	Threshold int // Percent of storage
+	Archive   bool
+}
+
+func prunePolicyFromConfig() prunePolicy {
+	c := config.Sub("sms.prune")
+	return prunePolicy{Threshold: c.GetInt("threshold"), Archive: c.GetBool("archive")}
+}
+
+func runSMSCapacity(modem drivers.BaseModem) error {
+	capacity, ok := modem.(drivers.ModemSMSCapacity)
+	if !ok {
+		return DriverSupportError{Driver: modem, Function: "SMS capacity"}
+	}
+
+	c, err := capacity.GetSMSCapacity()
+	if err != nil {
+		return err
+	}
+
+	threshold := prunePolicyFromConfig().Threshold
+	printCapacity("SIM", c.SIMUsed, c.SIMTotal, threshold)
+	printCapacity("Device", c.DeviceUsed, c.DeviceTotal, threshold)
+	return nil
+}
+
+func printCapacity(name string, used int, total int, threshold int) {
+	if total == 0 {
+		cfmt.Printf("{{%s:}}::cyan not available\n", name)
+		return
+	}
+
+	percent := used * 100 / total
+	switch {
+	case used >= total:
+		cfmt.Printf("{{%s:}}::cyan %d/%d {{(full)}}::red|bold\n", name, used, total)
+	case percent >= threshold:
+		cfmt.Printf("{{%s:}}::cyan %d/%d {{(%d%%)}}::yellow|bold\n", name, used, total, percent)
+	default:
+		cfmt.Printf("{{%s:}}::cyan %d/%d (%d%%)\n", name, used, total, percent)
+	}
+}
+
+func runSMSPrune(modem drivers.BaseModem, sms drivers.ModemSMS, a *SMSPruneArgs) error {
+	policy := prunePolicyFromConfig()
+	if a.Threshold != nil {
+		policy.Threshold = *a.Threshold
+	}
+	policy.Archive = policy.Archive || a.Archive
+
+	// One memory may be pruned before another fails
+	pruned, err := pruneSMS(modem, sms, policy, a.DryRun)
+	if a.DryRun {
+		for i := range pruned {
+			printSMS(&pruned[i])
+		}
+	}
+	if err == nil || len(pruned) > 0 {
+		cfmt.Printf("{{Pruned:}}::cyan %d messages\n", len(pruned))
+	}
+	return err
+}
+
+// Deletes the oldest read messages until every memory is back under the
+// threshold. Returns the deleted messages
+func pruneSMS(modem drivers.BaseModem, sms drivers.ModemSMS, policy prunePolicy, dryRun bool) ([]drivers.SMS, error) {
+	capacity, ok := modem.(drivers.ModemSMSCapacity)
+	if !ok {
+		return nil, DriverSupportError{Driver: modem, Function: "SMS capacity"}
+	}
+
+	c, err := capacity.GetSMSCapacity()
+	if err != nil {
+		return nil, err
+	}
+
+	// Deleting messages from one memory doesn't free the other, so each is pruned on its own
+	memories := []struct {
+		storage     drivers.SMSStorage
+		used, total int
+	}{
+		{drivers.SMSStorageDevice, c.DeviceUsed, c.DeviceTotal},
+		{drivers.SMSStorageSIM, c.SIMUsed, c.SIMTotal},
+	}
+
+	pruned := []drivers.SMS{}
+	for _, memory := range memories {
+		excess := pruneExcess(memory.used, memory.total, policy.Threshold)
+		if excess == 0 {
+			continue
+		}
+
+		// Drivers without storage support would read device memory instead
+		if !smsStorageSupported(sms, memory.storage) {
+			return pruned, DriverSupportError{Driver: modem, Function: "pruning SMS in " + string(memory.storage) + " memory"}
+		}
+
+		messages, err := pruneMemory(modem, sms, memory.storage, excess, policy, dryRun)
+		pruned = append(pruned, messages...)
+		if err != nil {
+			return pruned, err
+		}
+	}
+
+	return pruned, nil
+}
+
+func pruneMemory(modem drivers.BaseModem, sms drivers.ModemSMS, storage drivers.SMSStorage, excess int, policy prunePolicy, dryRun bool) ([]drivers.SMS, error) {
+	messages, err := drivers.CollectSMS(drivers.ReassembleSMS(sms.IterSMS(drivers.SMSQuery{Folder: drivers.SMSFolderAll, Storage: storage})))
+	if err != nil {
+		return nil, err
+	}
+
+	// Unread messages are never pruned
+	messages = slices.DeleteFunc(messages, func(m drivers.SMS) bool { return !m.Read })
+	slices.SortFunc(messages, func(a, b drivers.SMS) int {
+		if c := a.Time.Compare(b.Time); c != 0 {
+			return c
+		}
+		return drivers.CompareSMSID(a.ID, b.ID)
+	})
+
+	// Every part of a joined message takes up a slot
+	for i, freed := 0, 0; i < len(messages); i++ {
+		if freed >= excess {
+			messages = messages[:i]
+			break
+		}
+		freed += len(messages[i].IDs())
+	}
+
+	if dryRun || len(messages) == 0 {
+		return messages, nil
+	}
+
+	if policy.Archive {
+		store, err := openArchive()
+		if err != nil {
+			return nil, err
+		}
+		defer store.Close()
+
+		iccid := simICCID(modem)
+		for i := range messages {
+			if _, err := store.Add(archive.Record{SMS: messages[i], Modem: modemKey(modem), ICCID: iccid}); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	ids := []string{}
+	for i := range messages {
+		ids = append(ids, messages[i].IDs()...)
+	}
+	if err := sms.DeleteSMS(ids...); err != nil {
+		return nil, err
+	}
+
+	return messages, nil
+}
+
+// Device memory is always there, other memories need driver support
+func smsStorageSupported(sms drivers.ModemSMS, storage drivers.SMSStorage) bool {
+	if storage == drivers.SMSStorageDevice {
+		return true
+	}
+
+	storages, ok := sms.(drivers.ModemSMSStorage)
+	return ok && slices.Contains(storages.GetSMSStorages(), storage)
+}
+
+// Lists the storages messages can be read from, device memory first
+func smsStorages(sms drivers.ModemSMS) []drivers.SMSStorage {
+	storages := []drivers.SMSStorage{drivers.SMSStorageDevice}
+	if smsStorageSupported(sms, drivers.SMSStorageSIM) {
+		storages = append(storages, drivers.SMSStorageSIM)
+	}
+
+	return storages
+}
+
+// Number of messages to delete to get back under the threshold
+func pruneExcess(used int, total int, threshold int) int {
+	if total == 0 {
+		return 0
+	}
+
+	return max(used-total*threshold/100, 0)
+}
@@ -0,0 +1,278 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/alexflint/go-arg"
+	"github.com/brokenCursor/usb-modem-cli/drivers"
+	"github.com/brokenCursor/usb-modem-cli/state"
+	"github.com/i582/cfmt/cmd/cfmt"
+	"github.com/robfig/cron/v3"
+)
+
+const scheduleFile = "sms-schedule.json"
+
+// Runs kept for every scheduled message
+const maxScheduleRuns = 20
+
+type (
+	smsSchedule struct {
+		LastID  int            `json:"last_id"`
+		Entries []scheduledSMS `json:"entries"`
+	}
+
+	scheduledSMS struct {
+		ID       string        `json:"id"`
+		Phone    string        `json:"phone"`
+		Message  string        `json:"message"`
+		Encoding string        `json:"encoding"`
+		Cron     string        `json:"cron,omitempty"` // Empty for one-off messages
+		Next     time.Time     `json:"next"`           // Zero once a one-off message was sent
+		Runs     []scheduleRun `json:"runs,omitempty"`
+	}
+
+	scheduleRun struct {
+		Time   time.Time `json:"time"`
+		Outbox string    `json:"outbox,omitempty"` // ID of the message in the outbox
+		Result string    `json:"result,omitempty"` // Set by the outbox once it sent the message or gave up
+		Error  string    `json:"error,omitempty"`
+	}
+)
+
+// Outcomes of a run, pending while the message waits in the outbox
+const (
+	scheduleRunSent   = "sent"
+	scheduleRunFailed = "failed"
+)
+
+var ErrScheduleNotFound = errors.New("scheduled message not found")
+var ErrScheduleInPast = errors.New("time is in the past")
+
+func runSMSSchedule(parser *arg.Parser, a *SMSScheduleArgs) error {
+	switch {
+	case a.List != nil:
+		return listSchedule()
+	case a.Cancel != nil:
+		return cancelSchedule(a.Cancel.IDs)
+	}
+
+	if err := validate.Struct(a); err != nil || a.PhoneNumber == "" || (a.At == "" && a.Cron == "") {
+		parser.FailSubcommand("Use a valid -p and -m with either --at or --cron", "sms", "schedule")
+	}
+
+	phone, err := drivers.NormalizePhone(a.PhoneNumber)
+	if err != nil {
+		return err
+	}
+
+	message, err := readMessage(a.Message, a.File, drivers.SMSEncoding(a.Encoding))
+	if err != nil {
+		return err
+	}
+
+	entry := scheduledSMS{Phone: phone, Message: message, Encoding: a.Encoding, Cron: a.Cron}
+	if a.Cron != "" {
+		schedule, err := cron.ParseStandard(a.Cron)
+		if err != nil {
+			parser.FailSubcommand(fmt.Sprintf("Invalid cron expression: %v", err), "sms", "schedule")
+		}
+		entry.Next = schedule.Next(time.Now())
+	} else {
+		if entry.Next, err = parseFutureTime(a.At); err != nil {
+			parser.FailSubcommand(err.Error(), "sms", "schedule")
+		}
+		if entry.Next.Before(time.Now()) {
+			return ErrScheduleInPast
+		}
+	}
+
+	schedule := smsSchedule{}
+	err = state.Update(scheduleFile, &schedule, func() error {
+		schedule.LastID++
+		entry.ID = strconv.Itoa(schedule.LastID)
+		schedule.Entries = append(schedule.Entries, entry)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	cfmt.Printf("{{ID:}}::cyan %s\n{{Next:}}::cyan %s\n", entry.ID, entry.Next.Format(time.DateTime))
+	return nil
+}
+
+func listSchedule() error {
+	schedule := smsSchedule{}
+	if err := state.Load(scheduleFile, &schedule); err != nil {
+		return err
+	}
+
+	for _, entry := range schedule.Entries {
+		cfmt.Printf("{{ID:}}::cyan %s\n{{Recipient:}}::green %s\n", entry.ID, entry.Phone)
+		if entry.Cron != "" {
+			cfmt.Printf("{{Cron:}}::yellow %s\n", entry.Cron)
+		}
+		if entry.Next.IsZero() {
+			cfmt.Println("{{Next:}}::yellow done")
+		} else {
+			cfmt.Printf("{{Next:}}::yellow %s\n", entry.Next.Format(time.DateTime))
+		}
+
+		for _, run := range entry.Runs {
+			switch {
+			case run.Outbox == "":
+				cfmt.Printf("{{Run:}}::yellow %s {{failed}}::red|bold to queue: %s\n", run.Time.Format(time.DateTime), run.Error)
+			case run.Result == scheduleRunSent:
+				cfmt.Printf("{{Run:}}::yellow %s {{sent}}::green|bold as %s\n", run.Time.Format(time.DateTime), run.Outbox)
+			case run.Result == scheduleRunFailed:
+				cfmt.Printf("{{Run:}}::yellow %s {{failed}}::red|bold as %s: %s\n", run.Time.Format(time.DateTime), run.Outbox, run.Error)
+			default:
+				cfmt.Printf("{{Run:}}::yellow %s {{queued}}::yellow|bold as %s\n", run.Time.Format(time.DateTime), run.Outbox)
+			}
+		}
+		cfmt.Printf("{{Text:}}::#FA8100\n%s\n---\n", entry.Message)
+	}
+
+	return nil
+}
+
+func cancelSchedule(ids []string) error {
+	schedule := smsSchedule{}
+	return state.Update(scheduleFile, &schedule, func() error {
+		for _, id := range ids {
+			i := slices.IndexFunc(schedule.Entries, func(e scheduledSMS) bool { return e.ID == id })
+			if i < 0 {
+				return fmt.Errorf("%w: %s", ErrScheduleNotFound, id)
+			}
+			schedule.Entries = slices.Delete(schedule.Entries, i, i+1)
+		}
+		return nil
+	})
+}
+
+// Moves scheduled messages which are due into the outbox, which retries them
+// until they are sent
+func queueDueSMS() error {
+	// Only lock the spool when there is something to send
+	schedule := smsSchedule{}
+	if err := state.Load(scheduleFile, &schedule); err != nil {
+		return err
+	}
+	now := time.Now()
+	if !slices.ContainsFunc(schedule.Entries, func(e scheduledSMS) bool { return e.due(now) }) {
+		return nil
+	}
+
+	// Move entries on before queueing, so they aren't queued twice. Entries
+	// which fail to queue are put back afterwards
+	due := []scheduledSMS{}
+	err := state.Update(scheduleFile, &schedule, func() error {
+		for i := range schedule.Entries {
+			if entry := &schedule.Entries[i]; entry.due(now) {
+				due = append(due, *entry)
+				entry.Next = entry.nextRun(now)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	runs := map[string]scheduleRun{}
+	dueAt := map[string]time.Time{}
+	for _, entry := range due {
+		id, err := enqueueSMS(entry.Phone, entry.Message, drivers.SMSSendOptions{Encoding: drivers.SMSEncoding(entry.Encoding)})
+
+		run := scheduleRun{Time: time.Now(), Outbox: id}
+		if err != nil {
+			run.Error = err.Error()
+			dueAt[entry.ID] = entry.Next
+			cfmt.Fprintf(os.Stderr, "{{warning:}}::yellow scheduled message %s to %s: %v\n", entry.ID, entry.Phone, err)
+		} else {
+			cfmt.Printf("{{Queued:}}::cyan scheduled message %s to %s as %s\n", entry.ID, entry.Phone, id)
+		}
+		runs[entry.ID] = run
+	}
+
+	return state.Update(scheduleFile, &schedule, func() error {
+		for i := range schedule.Entries {
+			entry := &schedule.Entries[i]
+			if run, ok := runs[entry.ID]; ok {
+				// Due again, so the next check retries it
+				if next, ok := dueAt[entry.ID]; ok {
+					entry.Next = next
+				}
+				entry.addRun(run)
+			}
+		}
+		return nil
+	})
+}
+
+// Records the outcome of a scheduled message once the outbox is done with it.
+// Messages queued otherwise aren't in the schedule and leave it alone
+func recordScheduleResult(outbox string, sendErr error) error {
+	schedule := smsSchedule{}
+	if err := state.Load(scheduleFile, &schedule); err != nil {
+		return err
+	}
+	if !slices.ContainsFunc(schedule.Entries, func(e scheduledSMS) bool { return e.run(outbox) != nil }) {
+		return nil
+	}
+
+	return state.Update(scheduleFile, &schedule, func() error {
+		for i := range schedule.Entries {
+			if run := schedule.Entries[i].run(outbox); run != nil {
+				run.Result = scheduleRunSent
+				run.Error = ""
+				if sendErr != nil {
+					run.Result = scheduleRunFailed
+					run.Error = sendErr.Error()
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// Returns the latest run which queued the outbox message, if any
+func (e *scheduledSMS) run(outbox string) *scheduleRun {
+	for i := len(e.Runs) - 1; i >= 0; i-- {
+		if e.Runs[i].Outbox == outbox {
+			return &e.Runs[i]
+		}
+	}
+
+	return nil
+}
+
+func (e *scheduledSMS) addRun(run scheduleRun) {
+	e.Runs = append(e.Runs, run)
+	if len(e.Runs) > maxScheduleRuns {
+		e.Runs = e.Runs[len(e.Runs)-maxScheduleRuns:]
+	}
+}
+
+func (e *scheduledSMS) due(now time.Time) bool {
+	return !e.Next.IsZero() && !e.Next.After(now)
+}
+
+// Runs missed while no daemon was running are skipped, only the latest is sent
+func (e *scheduledSMS) nextRun(after time.Time) time.Time {
+	if e.Cron == "" {
+		return time.Time{}
+	}
+
+	// Checked when scheduling
+	schedule, err := cron.ParseStandard(e.Cron)
+	if err != nil {
+		return time.Time{}
+	}
+	return schedule.Next(after)
+}
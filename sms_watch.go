@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"runtime"
+	"time"
+
+	"github.com/brokenCursor/usb-modem-cli/config"
+	"github.com/brokenCursor/usb-modem-cli/drivers"
+	"github.com/i582/cfmt/cmd/cfmt"
+)
+
+func runSMSWatch(sms drivers.ModemSMS, a *SMSWatchArgs) error {
+	var match *regexp.Regexp
+	if a.Match != "" {
+		var err error
+		if match, err = regexp.Compile(a.Match); err != nil {
+			return fmt.Errorf("invalid --match: %w", err)
+		}
+	}
+
+	from := drivers.DisplayPhone(a.From)
+
+	watcher, err := newSMSWatcher(sms, "sms-watch", config.Sub("modem").GetString("host"))
+	if err != nil {
+		return err
+	}
+
+	for {
+		messages, err := watcher.Poll()
+		if err != nil {
+			// Modem may be rebooting, keep watching
+			cfmt.Fprintf(os.Stderr, "{{warning:}}::yellow %v\n", err)
+		}
+
+		for i := range messages {
+			// Only messages handled so far are acknowledged, so --once leaves the rest for later
+			if err := watcher.Ack(messages[i]); err != nil {
+				return err
+			}
+
+			switch {
+			case from != "" && !drivers.SamePhone(messages[i].Sender, from):
+				continue
+			case match != nil && !match.MatchString(messages[i].Message):
+				continue
+			}
+
+			printSMS(&messages[i])
+
+			if a.Exec != "" {
+				if err := execForSMS(a.Exec, &messages[i]); err != nil {
+					cfmt.Fprintf(os.Stderr, "{{warning:}}::yellow command failed: %v\n", err)
+				}
+			}
+
+			if a.Once {
+				return nil
+			}
+		}
+
+		time.Sleep(a.Interval)
+	}
+}
+
+// Runs a shell command with the message as JSON on stdin
+func execForSMS(command string, message *drivers.SMS) error {
+	data, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+
+	cmd := shellCommand(context.Background(), command)
+	cmd.Stdin = bytes.NewReader(data)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	return cmd.Run()
+}
+
+func shellCommand(ctx context.Context, command string) *exec.Cmd {
+	if runtime.GOOS == "windows" {
+		return exec.CommandContext(ctx, "cmd", "/C", command)
+	}
+	return exec.CommandContext(ctx, "sh", "-c", command)
+}
@@ -0,0 +1,136 @@
+package state
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"time"
+
+	"github.com/brokenCursor/usb-modem-cli/config"
+)
+
+// Returns the path of a file in the state directory, creating the directory if needed
+func Path(name string) (string, error) {
+	dir := config.Sub("state").GetString("dir")
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dir, name), nil
+}
+
+// Reads a JSON state file into v, leaving v as is if the file doesn't exist
+func Load(name string, v any) error {
+	path, err := Path(name)
+	if err != nil {
+		return err
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(data, v)
+}
+
+// Writes v into a JSON state file. The file is replaced at once, so it's never left half-written
+func Save(name string, v any) error {
+	path, err := Path(name)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return WriteFile(path, data)
+}
+
+const (
+	lockTimeout = 5 * time.Second
+	lockRetry   = 50 * time.Millisecond
+	staleLock   = time.Minute // Left behind by a process which crashed
+)
+
+var ErrLocked = errors.New("state file is locked by another process")
+
+// Replaces v with a JSON state file, lets fn change it and saves it. Other processes
+// using Update on the same file wait for it. Nothing is saved if fn fails
+func Update(name string, v any, fn func() error) error {
+	unlock, err := lock(name)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	// Drop whatever v held, the file may have changed since
+	if elem := reflect.ValueOf(v).Elem(); elem.Kind() == reflect.Map {
+		elem.Set(reflect.MakeMap(elem.Type()))
+	} else {
+		elem.SetZero()
+	}
+	if err := Load(name, v); err != nil {
+		return err
+	}
+	if err := fn(); err != nil {
+		return err
+	}
+
+	return Save(name, v)
+}
+
+func lock(name string) (func(), error) {
+	path, err := Path(name + ".lock")
+	if err != nil {
+		return nil, err
+	}
+
+	deadline := time.Now().Add(lockTimeout)
+	for {
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+		if err == nil {
+			f.Close()
+			return func() { os.Remove(path) }, nil
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return nil, err
+		}
+
+		if info, err := os.Stat(path); err == nil && time.Since(info.ModTime()) > staleLock {
+			os.Remove(path)
+			continue
+		}
+
+		if time.Now().After(deadline) {
+			return nil, ErrLocked
+		}
+		time.Sleep(lockRetry)
+	}
+}
+
+// Writes data to a temporary file next to path and renames it over path
+func WriteFile(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var byteUnits = []string{"B", "KB", "MB", "GB", "TB"}
+
+// Formats a byte count using 1024-based units, same as the modem web UIs
+func formatBytes(n uint64) string {
+	value := float64(n)
+	unit := 0
+	for value >= 1024 && unit < len(byteUnits)-1 {
+		value /= 1024
+		unit++
+	}
+
+	if unit == 0 {
+		return fmt.Sprintf("%d B", n)
+	}
+	return fmt.Sprintf("%.2f %s", value, byteUnits[unit])
+}
+
+// Parses sizes like "500MB" or "20 GB" into bytes
+func parseBytes(s string) (uint64, error) {
+	s = strings.ToUpper(strings.TrimSpace(s))
+
+	for unit := len(byteUnits) - 1; unit >= 0; unit-- {
+		number, ok := strings.CutSuffix(s, byteUnits[unit])
+		if !ok {
+			continue
+		}
+
+		value, err := strconv.ParseFloat(strings.TrimSpace(number), 64)
+		if err != nil || value < 0 {
+			return 0, fmt.Errorf("invalid size %q", s)
+		}
+
+		for i := 0; i < unit; i++ {
+			value *= 1024
+		}
+		return uint64(value), nil
+	}
+
+	return 0, fmt.Errorf("invalid size %q, expected a unit like MB or GB", s)
+}
+
+var timeLayouts = []string{time.RFC3339, "2006-01-02T15:04:05", "2006-01-02T15:04", time.DateTime, time.DateOnly}
+
+// Parses a point in time given either as a timestamp or as a duration before now
+func parseTime(s string) (time.Time, error) {
+	if d, err := time.ParseDuration(s); err == nil {
+		return time.Now().Add(-d), nil
+	}
+
+	for _, layout := range timeLayouts {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("invalid time %q, expected a duration like 24h or a date like 2006-01-02T15:04", s)
+}
+
+// Parses a point in time given either as a timestamp or as a duration from now
+func parseFutureTime(s string) (time.Time, error) {
+	if d, err := time.ParseDuration(s); err == nil {
+		return time.Now().Add(d), nil
+	}
+
+	return parseTime(s)
+}
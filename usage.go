@@ -0,0 +1,102 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/alexflint/go-arg"
+	"github.com/brokenCursor/usb-modem-cli/drivers"
+	"github.com/i582/cfmt/cmd/cfmt"
+)
+
+func runUsage(parser *arg.Parser, modem drivers.BaseModem) error {
+	traffic, ok := modem.(drivers.ModemTraffic)
+	if !ok {
+		return DriverSupportError{Driver: modem, Function: "usage statistics"}
+	}
+
+	if err := validate.Struct(args.Usage); err != nil {
+		logger.With("err", err.Error()).Debug("usage validation error")
+		parser.FailSubcommand("Use only one of --limit, --time-limit and --no-limit", "usage")
+	}
+
+	// Apply changes first so the output reflects them
+	if args.Usage.Reset {
+		reset, ok := modem.(drivers.ModemTrafficReset)
+		if !ok {
+			return DriverSupportError{Driver: modem, Function: "usage reset"}
+		}
+
+		if err := reset.ResetTrafficStats(); err != nil {
+			return err
+		}
+	}
+
+	switch {
+	case args.Usage.NoLimit:
+		if err := traffic.SetDataLimit(drivers.DataLimit{Enabled: false}); err != nil {
+			return err
+		}
+	case args.Usage.Limit != "":
+		volume, err := parseBytes(args.Usage.Limit)
+		if err != nil {
+			parser.FailSubcommand(err.Error(), "usage")
+		}
+
+		limit := drivers.DataLimit{Enabled: true, Type: drivers.DataLimitVolume, Volume: volume, AlertPercent: args.Usage.Alert}
+		if err := traffic.SetDataLimit(limit); err != nil {
+			return err
+		}
+	case args.Usage.TimeLimit > 0:
+		limit := drivers.DataLimit{Enabled: true, Type: drivers.DataLimitTime, Time: args.Usage.TimeLimit, AlertPercent: args.Usage.Alert}
+		if err := traffic.SetDataLimit(limit); err != nil {
+			return err
+		}
+	}
+
+	stats, err := traffic.GetTrafficStats()
+	if err != nil {
+		return err
+	}
+
+	cfmt.Println("{{Session}}::bold")
+	cfmt.Printf("{{Received:}}::green %s\n{{Sent:}}::green %s\n{{Duration:}}::green %s\n", formatBytes(stats.SessionRX), formatBytes(stats.SessionTX), stats.SessionDuration)
+	cfmt.Printf("{{Speed:}}::green ↓ %s/s ↑ %s/s\n", formatBytes(stats.RXRate), formatBytes(stats.TXRate))
+	cfmt.Println("{{This month}}::bold")
+	cfmt.Printf("{{Received:}}::green %s\n{{Sent:}}::green %s\n{{Total:}}::green %s\n{{Duration:}}::green %s\n", formatBytes(stats.MonthlyRX), formatBytes(stats.MonthlyTX), formatBytes(stats.MonthlyRX+stats.MonthlyTX), stats.MonthlyDuration)
+
+	limit, err := traffic.GetDataLimit()
+	if err != nil {
+		return err
+	}
+
+	if !limit.Enabled {
+		cfmt.Println("{{Limit:}}::cyan none")
+		return nil
+	}
+
+	var used float64
+	switch limit.Type {
+	case drivers.DataLimitTime:
+		cfmt.Printf("{{Limit:}}::cyan %s per month\n", limit.Time)
+		if limit.Time > 0 {
+			used = float64(stats.MonthlyDuration) / float64(limit.Time) * 100
+		}
+	default:
+		cfmt.Printf("{{Limit:}}::cyan %s per month\n", formatBytes(limit.Volume))
+		if limit.Volume > 0 {
+			used = float64(stats.MonthlyRX+stats.MonthlyTX) / float64(limit.Volume) * 100
+		}
+	}
+
+	usedStr := fmt.Sprintf("%.1f%%", used)
+	switch {
+	case used >= 100:
+		cfmt.Printf("{{Used:}}::cyan {{%s}}::red|bold\n", usedStr)
+	case limit.AlertPercent > 0 && used >= float64(limit.AlertPercent):
+		cfmt.Printf("{{Used:}}::cyan {{%s}}::yellow|bold (alert at %d%%)\n", usedStr, limit.AlertPercent)
+	default:
+		cfmt.Printf("{{Used:}}::cyan {{%s}}::green (alert at %d%%)\n", usedStr, limit.AlertPercent)
+	}
+
+	return nil
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"slices"
+
+	"github.com/brokenCursor/usb-modem-cli/drivers"
+	"github.com/brokenCursor/usb-modem-cli/state"
+)
+
+// Follows the inbox for new messages. Each watcher keeps its own last seen ID,
+// so several of them can follow the same modem
+type smsWatcher struct {
+	sms     drivers.ModemSMS
+	name    string // State file
+	modem   string // Key of the modem in the state file
+	lastIDs map[string]string
+	notify  *drivers.SMSNotify // Inbox state as of the last read
+}
+
+func newSMSWatcher(sms drivers.ModemSMS, name string, modem string) (*smsWatcher, error) {
+	w := &smsWatcher{sms: sms, name: name + ".json", modem: modem, lastIDs: map[string]string{}}
+	if err := state.Load(w.name, &w.lastIDs); err != nil {
+		return nil, err
+	}
+
+	return w, nil
+}
+
+// Returns messages which arrived after the last acknowledged one, oldest first.
+// On the very first poll nothing is returned, only the newest message is remembered
+func (w *smsWatcher) Poll() ([]drivers.SMS, error) {
+	// Skip reading the inbox if the modem says nothing has changed. The flag
+	// gets reset by the web UI, so the unread count is watched as well
+	var notify *drivers.SMSNotify
+	if notifier, ok := w.sms.(drivers.ModemSMSNotify); ok {
+		var err error
+		if notify, err = notifier.GetSMSNotify(); err != nil {
+			return nil, err
+		}
+
+		if !notify.Received && w.notify != nil && *w.notify == *notify {
+			return nil, nil
+		}
+	}
+
+	lastID, known := w.lastIDs[w.modem]
+
+	// Messages come newest first, stop at the last seen one
+	messages := []drivers.SMS{}
+	it := drivers.ReassembleSMS(w.sms.IterSMS(drivers.SMSQuery{Folder: drivers.SMSFolderInbox}))
+	for it.Next() {
+		if known && drivers.CompareSMSID(it.SMS().ID, lastID) <= 0 {
+			break
+		}
+		messages = append(messages, *it.SMS())
+
+		if !known {
+			break
+		}
+	}
+	if err := it.Err(); err != nil {
+		return nil, err
+	}
+
+	// First poll only remembers where the inbox stands. An empty inbox is
+	// remembered as an empty ID, which sorts before all others
+	if !known {
+		w.notify = notify
+		if len(messages) == 0 {
+			return nil, w.save("")
+		}
+		return nil, w.Ack(messages...)
+	}
+
+	if len(messages) == 0 {
+		w.notify = notify
+		return nil, nil
+	}
+
+	// Read the inbox again next time, in case some of these aren't acknowledged
+	w.notify = nil
+
+	slices.Reverse(messages)
+	return messages, nil
+}
+
+// Marks messages as handled, so later polls start after them. Messages which
+// weren't acknowledged come back on the next poll
+func (w *smsWatcher) Ack(messages ...drivers.SMS) error {
+	// Parts may come out of order, so look for the highest ID
+	newest := w.lastIDs[w.modem]
+	for i := range messages {
+		for _, id := range messages[i].IDs() {
+			if newest == "" || drivers.CompareSMSID(id, newest) > 0 {
+				newest = id
+			}
+		}
+	}
+	if newest == "" || newest == w.lastIDs[w.modem] {
+		return nil
+	}
+
+	return w.save(newest)
+}
+
+// Stores the last handled ID of the modem
+func (w *smsWatcher) save(id string) error {
+	// Other watchers on other modems may share the file
+	lastIDs := map[string]string{}
+	err := state.Update(w.name, &lastIDs, func() error {
+		lastIDs[w.modem] = id
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	w.lastIDs = lastIDs
+	return nil
+}
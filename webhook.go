@@ -0,0 +1,261 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/brokenCursor/usb-modem-cli/config"
+	"github.com/brokenCursor/usb-modem-cli/drivers"
+	"github.com/brokenCursor/usb-modem-cli/state"
+	"github.com/i582/cfmt/cmd/cfmt"
+)
+
+const webhookSpoolFile = "webhook-spool.json"
+
+const (
+	webhookSignatureHeader = "X-Mcli-Signature" // "sha256=" and the hex HMAC-SHA256 of the body, keyed with the route's secret
+	webhookDeliveryHeader  = "X-Mcli-Delivery"  // Same for every attempt, lets endpoints drop duplicates
+)
+
+type (
+	webhookRoute struct {
+		Name   string `mapstructure:"name"`
+		URL    string `mapstructure:"url"`
+		Secret string `mapstructure:"secret"` // Signature is left out without one
+		From   string `mapstructure:"from"`   // Only messages from this number or sender name
+		Match  string `mapstructure:"match"`  // Only messages matching this regular expression
+
+		match *regexp.Regexp
+	}
+
+	// Messages wait in the spool until the endpoint accepts them
+	webhookSpool struct {
+		LastID     int               `json:"last_id"`
+		Deliveries []webhookDelivery `json:"deliveries"`
+	}
+
+	webhookDelivery struct {
+		ID          string         `json:"id"`
+		Route       string         `json:"route"`
+		Payload     webhookPayload `json:"payload"`
+		Attempts    int            `json:"attempts"`
+		NextAttempt time.Time      `json:"next_attempt"`
+		LastError   string         `json:"last_error,omitempty"`
+	}
+
+	// Body POSTed to endpoints
+	webhookPayload struct {
+		drivers.SMS
+		Modem string `json:"modem"`
+	}
+
+	webhookForwarder struct {
+		modem   drivers.BaseModem
+		watcher *smsWatcher
+		routes  []webhookRoute
+		policy  retryPolicy
+		client  *http.Client
+	}
+)
+
+var ErrNoRoutes = errors.New("webhook.routes has no routes")
+
+func newWebhookForwarder(modem drivers.BaseModem, sms drivers.ModemSMS) (*webhookForwarder, error) {
+	c := config.Sub("webhook")
+
+	routes := []webhookRoute{}
+	if err := c.UnmarshalKey("routes", &routes); err != nil {
+		return nil, fmt.Errorf("invalid webhook.routes: %w", err)
+	}
+	if len(routes) == 0 {
+		return nil, ErrNoRoutes
+	}
+
+	for i := range routes {
+		route := &routes[i]
+		if route.Name == "" {
+			route.Name = strconv.Itoa(i)
+		}
+		if err := validate.Var(route.URL, "required,http_url"); err != nil {
+			return nil, fmt.Errorf("webhook route %s: invalid url %q", route.Name, route.URL)
+		}
+
+		route.From = drivers.DisplayPhone(route.From)
+
+		if route.Match != "" {
+			var err error
+			if route.match, err = regexp.Compile(route.Match); err != nil {
+				return nil, fmt.Errorf("webhook route %s: invalid match: %w", route.Name, err)
+			}
+		}
+	}
+
+	watcher, err := newSMSWatcher(sms, "webhook", config.Sub("modem").GetString("host"))
+	if err != nil {
+		return nil, err
+	}
+
+	return &webhookForwarder{
+		modem:   modem,
+		watcher: watcher,
+		routes:  routes,
+		policy:  retryPolicyFromConfig("webhook"),
+		client:  &http.Client{Timeout: c.GetDuration("timeout")},
+	}, nil
+}
+
+// Spools new messages and delivers whatever is due. Messages are spooled
+// first, so they survive the endpoint being down
+func (f *webhookForwarder) Poll() error {
+	messages, err := f.watcher.Poll()
+	if err != nil {
+		return err
+	}
+
+	if len(messages) > 0 {
+		if err := f.spool(messages); err != nil {
+			return err
+		}
+		if err := f.watcher.Ack(messages...); err != nil {
+			return err
+		}
+	}
+
+	return f.deliver()
+}
+
+func (f *webhookForwarder) spool(messages []drivers.SMS) error {
+	spool := webhookSpool{}
+	return state.Update(webhookSpoolFile, &spool, func() error {
+		for i := range messages {
+			for _, route := range f.routes {
+				if !route.matches(&messages[i]) {
+					continue
+				}
+
+				spool.LastID++
+				spool.Deliveries = append(spool.Deliveries, webhookDelivery{
+					ID:          strconv.Itoa(spool.LastID),
+					Route:       route.Name,
+					Payload:     webhookPayload{SMS: messages[i], Modem: modemKey(f.modem)},
+					NextAttempt: time.Now(),
+				})
+			}
+		}
+		return nil
+	})
+}
+
+func (f *webhookForwarder) deliver() error {
+	spool := webhookSpool{}
+	if err := state.Load(webhookSpoolFile, &spool); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	results := map[string]error{}
+	down := map[string]bool{} // Routes which already failed in this round
+	for _, delivery := range spool.Deliveries {
+		if delivery.NextAttempt.After(now) || down[delivery.Route] {
+			continue
+		}
+
+		i := slices.IndexFunc(f.routes, func(route webhookRoute) bool { return route.Name == delivery.Route })
+		if i < 0 {
+			// Route was removed from config, nowhere to send it
+			cfmt.Fprintf(os.Stderr, "{{warning:}}::yellow webhook route %s is gone, dropping message %s\n", delivery.Route, delivery.Payload.ID)
+			results[delivery.ID] = nil
+			continue
+		}
+
+		err := f.post(&f.routes[i], &delivery)
+		results[delivery.ID] = err
+		if err == nil {
+			continue
+		}
+		down[delivery.Route] = true
+
+		if f.policy.exhausted(delivery.Attempts + 1) {
+			cfmt.Fprintf(os.Stderr, "{{warning:}}::yellow webhook %s failed %d times, dropping message %s: %v\n", delivery.Route, delivery.Attempts+1, delivery.Payload.ID, err)
+			results[delivery.ID] = nil
+			continue
+		}
+
+		cfmt.Fprintf(os.Stderr, "{{warning:}}::yellow webhook %s: %v\n", delivery.Route, err)
+	}
+
+	if len(results) == 0 {
+		return nil
+	}
+
+	return state.Update(webhookSpoolFile, &spool, func() error {
+		spool.Deliveries = slices.DeleteFunc(spool.Deliveries, func(delivery webhookDelivery) bool {
+			err, ok := results[delivery.ID]
+			return ok && err == nil
+		})
+
+		for i := range spool.Deliveries {
+			delivery := &spool.Deliveries[i]
+			if err := results[delivery.ID]; err != nil {
+				delivery.Attempts++
+				delivery.NextAttempt = now.Add(f.policy.delay(delivery.Attempts))
+				delivery.LastError = err.Error()
+			}
+		}
+		return nil
+	})
+}
+
+func (f *webhookForwarder) post(route *webhookRoute, delivery *webhookDelivery) error {
+	body, err := json.Marshal(&delivery.Payload)
+	if err != nil {
+		return err
+	}
+
+	request, err := http.NewRequest("POST", route.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set(webhookDeliveryHeader, delivery.ID)
+	if route.Secret != "" {
+		mac := hmac.New(sha256.New, []byte(route.Secret))
+		mac.Write(body)
+		request.Header.Set(webhookSignatureHeader, "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	resp, err := f.client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("response status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func (r *webhookRoute) matches(message *drivers.SMS) bool {
+	switch {
+	case r.From != "" && !drivers.SamePhone(message.Sender, r.From):
+		return false
+	case r.match != nil && !r.match.MatchString(message.Message):
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/alexflint/go-arg"
+	"github.com/brokenCursor/usb-modem-cli/drivers"
+	"github.com/i582/cfmt/cmd/cfmt"
+)
+
+func runWiFi(parser *arg.Parser, modem drivers.BaseModem) error {
+	wifi, ok := modem.(drivers.ModemWiFi)
+	if !ok {
+		return DriverSupportError{Driver: modem, Function: "Wi-Fi"}
+	}
+
+	switch {
+	case args.WiFi.Status != nil:
+		settings, err := wifi.GetWiFiSettings()
+		if err != nil {
+			return err
+		}
+
+		if settings.Enabled {
+			cfmt.Println("Status: {{on}}::green|bold")
+		} else {
+			cfmt.Println("Status: {{off}}::red|bold")
+		}
+		cfmt.Printf("{{SSID:}}::cyan %s\n{{Security:}}::cyan %s\n", settings.SSID, settings.Security)
+		if args.WiFi.Status.ShowPassword {
+			cfmt.Printf("{{Passphrase:}}::cyan %s\n", settings.Passphrase)
+		}
+		if settings.Channel == 0 {
+			cfmt.Println("{{Channel:}}::cyan auto")
+		} else {
+			cfmt.Printf("{{Channel:}}::cyan %d\n", settings.Channel)
+		}
+		if settings.Broadcast {
+			cfmt.Println("{{Broadcast:}}::cyan on")
+		} else {
+			cfmt.Println("{{Broadcast:}}::cyan off")
+		}
+	case args.WiFi.Set != nil:
+		if err := validate.Struct(args.WiFi.Set); err != nil {
+			logger.With("err", err.Error()).Debug("wifi set validation error")
+			parser.FailSubcommand("Unknown values", "wifi", "set")
+		}
+
+		// Only change what has been asked for
+		settings, err := wifi.GetWiFiSettings()
+		if err != nil {
+			return err
+		}
+
+		set := args.WiFi.Set
+		if set.SSID != nil {
+			settings.SSID = *set.SSID
+		}
+		if set.Password != nil {
+			settings.Passphrase = *set.Password
+		}
+		if set.Security != nil {
+			settings.Security = *set.Security
+		}
+		if set.Channel != nil {
+			settings.Channel = *set.Channel
+		}
+		if set.Broadcast != nil {
+			settings.Broadcast = *set.Broadcast == "on"
+		}
+		if set.Enabled != nil {
+			settings.Enabled = *set.Enabled == "on"
+		}
+
+		if settings.Security != drivers.WiFiSecurityOpen && len(settings.Passphrase) < 8 {
+			parser.FailSubcommand("Secured networks need a --password of at least 8 characters", "wifi", "set")
+		}
+
+		return wifi.SetWiFiSettings(*settings)
+	case args.WiFi.Clients != nil:
+		clients, err := wifi.ListWiFiClients()
+		if err != nil {
+			return err
+		}
+
+		for _, client := range clients {
+			cfmt.Printf("{{%s}}::cyan {{%s}}::green %s\n", client.MAC, client.IP, client.Hostname)
+		}
+	case args.WiFi.Block != nil:
+		if err := validate.Struct(args.WiFi.Block); err != nil {
+			logger.With("err", err.Error()).Debug("wifi block validation error")
+			parser.FailSubcommand("Unknown action or invalid MAC address", "wifi", "block")
+		}
+
+		macs, err := wifi.GetMACBlockList()
+		if err != nil {
+			return err
+		}
+
+		switch args.WiFi.Block.Action {
+		case "list":
+			for _, mac := range macs {
+				cfmt.Printf("{{%s}}::cyan\n", mac)
+			}
+			return nil
+		case "add":
+			for _, mac := range args.WiFi.Block.MACs {
+				if mac = strings.ToUpper(mac); !slices.Contains(macs, mac) {
+					macs = append(macs, mac)
+				}
+			}
+		case "remove":
+			macs = slices.DeleteFunc(macs, func(mac string) bool {
+				return slices.ContainsFunc(args.WiFi.Block.MACs, func(m string) bool { return strings.EqualFold(m, mac) })
+			})
+		}
+
+		return wifi.SetMACBlockList(macs)
+	default:
+		parser.FailSubcommand("Missing or unknown action", "wifi")
+	}
+
+	return nil
+}